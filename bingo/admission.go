@@ -0,0 +1,207 @@
+package bingo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// Admission decides which connections the server lets in, before any room
+// sees them. It is shared by every room, so it is safe for concurrent use.
+type Admission struct {
+	// Hosts web pages may open the game from, such as "example.com:8080",
+	// or "*" for any. Pages served by the game server itself and clients
+	// that send no Origin, like the terminal client, are always let in.
+	Origins []string
+	// Networks clients may connect from, empty for any.
+	Allow []*net.IPNet
+	// Networks refused even when Allow lets them in.
+	Deny []*net.IPNet
+	// Websockets one IP may have open at once, 0 for no limit.
+	MaxPerIP int
+
+	lock  sync.Mutex
+	conns map[string]int
+}
+
+// ParseNetworks reads a comma separated list of networks in CIDR notation.
+// Plain IP addresses stand for themselves.
+func ParseNetworks(list string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an IP address or network", s)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func contains(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP returns the address a request came from. Requests over a Unix
+// socket come from this machine.
+func remoteIP(r *http.Request) net.IP {
+	if _, ok := r.Context().Value(http.LocalAddrContextKey).(*net.UnixAddr); ok {
+		return net.IPv4(127, 0, 0, 1)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// checkOrigin reports whether the page a request comes from may open the
+// game. A nil Admission only lets in the server's own pages.
+func (a *Admission) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	if a == nil {
+		return false
+	}
+	for _, allowed := range a.Origins {
+		if allowed == "*" || strings.EqualFold(allowed, u.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+// admit checks where a request comes from. It returns the HTTP status to
+// refuse the request with.
+func (a *Admission) admit(r *http.Request) (int, error) {
+	if !a.checkOrigin(r) {
+		return http.StatusForbidden, fmt.Errorf("pages from %s may not open this game", r.Header.Get("Origin"))
+	}
+	if a == nil {
+		return http.StatusOK, nil
+	}
+	ip := remoteIP(r)
+	if ip == nil || contains(a.Deny, ip) || len(a.Allow) > 0 && !contains(a.Allow, ip) {
+		return http.StatusForbidden, fmt.Errorf("connections from %s are not allowed", ip)
+	}
+	return http.StatusOK, nil
+}
+
+// acquire takes one of the connections ip may have open. It reports false
+// when ip is at MaxPerIP, otherwise release has to be called once the
+// connection is closed.
+func (a *Admission) acquire(ip net.IP) (release func(), ok bool) {
+	if a == nil || a.MaxPerIP == 0 {
+		return func() {}, true
+	}
+	key := ip.String()
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.conns == nil {
+		a.conns = make(map[string]int)
+	}
+	if a.conns[key] >= a.MaxPerIP {
+		return nil, false
+	}
+	a.conns[key]++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			a.lock.Lock()
+			defer a.lock.Unlock()
+			if a.conns[key]--; a.conns[key] <= 0 {
+				delete(a.conns, key)
+			}
+		})
+	}, true
+}
+
+// upgrade turns a request into a player connection, counting it against
+// the IP's limit until it is closed. It writes the HTTP error itself when
+// the connection is refused.
+func (g *Game) upgrade(w http.ResponseWriter, r *http.Request) (PlayerConn, error) {
+	release, ok := g.Admission.acquire(remoteIP(r))
+	if !ok {
+		http.Error(w, "Too many connections from your address", http.StatusTooManyRequests)
+		return nil, fmt.Errorf("too many connections from %s", r.RemoteAddr)
+	}
+	upgrader := websocket.Upgrader{Subprotocols: Subprotocols(), CheckOrigin: g.Admission.checkOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	c := NewWebSocketConn(conn).(*wsConn)
+	c.release = release
+	return c, nil
+}
+
+// RejectedError is why a server refused a connection.
+type RejectedError struct {
+	Status int
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("server refused the connection: %s", http.StatusText(e.Status))
+	}
+	return fmt.Sprintf("server refused the connection: %s (%s)", e.Reason, http.StatusText(e.Status))
+}
+
+// DialError explains why dialing a server failed, with the reason the server
+// gave if it refused the connection.
+func DialError(err error, resp *http.Response) error {
+	if resp == nil || !errors.Is(err, websocket.ErrBadHandshake) {
+		return err
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return &RejectedError{Status: resp.StatusCode, Reason: strings.TrimSpace(string(body))}
+}
+
+// CloseReason returns the reason the server gave for closing a connection,
+// empty if it gave none.
+func CloseReason(err error) string {
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		return closeErr.Text
+	}
+	return ""
+}
@@ -1,79 +1,307 @@
 package bingo
 
-const (
-	errorCommand int = iota
-	PlayerNameCommand
-	PlayerIDCommand
-	PlayersListCommand
-	GameConfigCommand
-	PlayerBoardCommand
-	GameStatusCommand
-	GameMoveCommand
-	GameScoreIndexCommand
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jayakrishnan-jayu/bin-go/bingo/engine"
 )
 
-type RequestCommand struct {
-	Command int `json:"command"`
-}
+const (
+	PlayerNameCommand     MessageType = "player_name"
+	PlayerIDCommand       MessageType = "player_id"
+	PlayersListCommand    MessageType = "players_list"
+	GameConfigCommand     MessageType = "game_config"
+	PlayerBoardCommand    MessageType = "player_board"
+	GameStatusCommand     MessageType = "game_status"
+	GameMoveCommand       MessageType = "game_move"
+	GameScoreIndexCommand MessageType = "game_score_index"
+	ErrorCommand          MessageType = "error"
+	GameTimeoutCommand    MessageType = "game_timeout"
+	GameStateCommand      MessageType = "game_state"
+	SpectatorViewCommand  MessageType = "spectator_view"
+	GameResultCommand     MessageType = "game_result"
+	RoundVoteCommand      MessageType = "round_vote"
+	RoundVotesCommand     MessageType = "round_votes"
+	LeaderboardCommand    MessageType = "leaderboard"
+	ServerShutdownCommand MessageType = "server_shutdown"
+)
 
 type PlayerName struct {
-	Command int    `json:"command"`
-	Name    string `json:"name"`
+	Name string `json:"name"`
 }
 
 type PlayerID struct {
-	Command int   `json:"command"`
-	ID      uint8 `json:"id"`
+	ID    uint8  `json:"id"`
+	Token string `json:"token"`
 }
 
 type PlayersList struct {
-	Command int       `json:"command"`
 	Players []*Client `json:"players"`
 }
 
+// Numbers are numbers of a board. They go over the wire as an array of
+// numbers, where encoding/json would send a []uint8 as a base64 string.
+type Numbers []uint8
+
+func (n Numbers) MarshalJSON() ([]byte, error) {
+	if n == nil {
+		return []byte("null"), nil
+	}
+	ints := make([]int, len(n))
+	for i, x := range n {
+		ints[i] = int(x)
+	}
+	return json.Marshal(ints)
+}
+
+// UnmarshalJSON also reads the base64 strings journals used to be written
+// with.
+func (n *Numbers) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return err
+		}
+		*n = b
+		return nil
+	}
+	var ints []int
+	if err := json.Unmarshal(data, &ints); err != nil {
+		return err
+	}
+	if ints == nil {
+		*n = nil
+		return nil
+	}
+	numbers := make(Numbers, len(ints))
+	for i, x := range ints {
+		if x < 0 || x > 255 {
+			return fmt.Errorf("board number %d is out of range", x)
+		}
+		numbers[i] = uint8(x)
+	}
+	*n = numbers
+	return nil
+}
+
+// Board is a grid of numbers, sent as an array of rows of numbers. A nil
+// Board is sent as null.
+type Board [][]uint8
+
+func (b Board) MarshalJSON() ([]byte, error) {
+	if b == nil {
+		return []byte("null"), nil
+	}
+	rows := make([]Numbers, len(b))
+	for i, row := range b {
+		rows[i] = row
+	}
+	return json.Marshal(rows)
+}
+
+func (b *Board) UnmarshalJSON(data []byte) error {
+	var rows []Numbers
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return err
+	}
+	if rows == nil {
+		*b = nil
+		return nil
+	}
+	board := make(Board, len(rows))
+	for i, row := range rows {
+		board[i] = row
+	}
+	*b = board
+	return nil
+}
+
 type PlayersBoard struct {
-	Command int        `json:"command"`
-	Board   *[][]uint8 `json:"board"`
+	Board Board `json:"board"`
 }
 type GameConfig struct {
-	Command     int   `json:"command"`
 	IsLobbyMode bool  `json:"is_lobby_mode"`
 	BoardSize   uint8 `json:"board_size"`
+	WinLines    uint8 `json:"win_lines"`
+	Diagonals   bool  `json:"diagonals"`
+	MaxPlayers  int   `json:"max_players"`
+	// Milliseconds each player has to move, 0 for no limit.
+	TurnTimeout   int64         `json:"turn_timeout"`
+	TimeoutPolicy TimeoutPolicy `json:"timeout_policy"`
+	EndCondition  EndCondition  `json:"end_condition"`
+	Finishers     int           `json:"finishers"`
+	// Seed of the round, which the boards and random moves are drawn from
+	// with engine.NewRand. Only the journal keeps it: players are dealt
+	// their boards, as the seed would give away everyone else's, and the
+	// seeds of the rounds after it.
+	Seed int64 `json:"seed,omitempty"`
 }
 
 type GameStatus struct {
-	Command  int   `json:"command"`
 	PlayerId uint8 `json:"player_id"`
+	// Identifies the turn, moves have to echo it.
+	Turn uint64 `json:"turn"`
+	// Milliseconds until the turn deadline, 0 if there is none.
+	TimeLeft int64 `json:"time_left"`
 }
 
 type GameMove struct {
-	Command int     `json:"command"`
-	Change  uint8   `json:"change"`
-	Name    string  `json:"name"`
-	Author  *Client `json:"-"`
+	Change uint8 `json:"change"`
+	// The turn the move was made for.
+	Turn uint64 `json:"turn"`
+	Name string `json:"name"`
+	// Set when the server moved for a player who ran out of time.
+	Auto   bool    `json:"auto"`
+	Author *Client `json:"-"`
+}
+
+type GameTimeout struct {
+	PlayerId uint8         `json:"player_id"`
+	Name     string        `json:"name"`
+	Action   TimeoutPolicy `json:"action"`
 }
 
 type GameScoreIndex struct {
-	Command int   `json:"command"`
-	Score   uint8 `json:"score"`
+	Score uint8 `json:"score"`
 }
 
+// Error reports a mistake back to the client that made it.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// GameState is sent to a client resuming its seat.
+type GameState struct {
+	Crossed Numbers    `json:"crossed"`
+	Moves   []GameMove `json:"moves"`
+}
+
+type PlayerView struct {
+	Id         uint8  `json:"id"`
+	Name       string `json:"name"`
+	Board      Board  `json:"board"`
+	Score      uint8  `json:"score"`
+	ScoreIndex uint8  `json:"score_index"`
+	Connected  bool   `json:"connected"`
+}
+
+// SpectatorView is everything a spectator sees, sent on every turn.
+type SpectatorView struct {
+	Players       []PlayerView `json:"players"`
+	Crossed       Numbers      `json:"crossed"`
+	CurrentPlayer uint8        `json:"current_player"`
+}
+
+type Standing struct {
+	Rank     int    `json:"rank"`
+	Id       uint8  `json:"id"`
+	Name     string `json:"name"`
+	Lines    uint8  `json:"lines"`
+	Moves    int    `json:"moves"`
+	Finished bool   `json:"finished"`
+}
+
+type LeaderboardEntry struct {
+	Id     uint8  `json:"id"`
+	Name   string `json:"name"`
+	Rounds int    `json:"rounds"`
+	Wins   int    `json:"wins"`
+	Points int    `json:"points"`
+}
+
+// GameResult is broadcast when the game is over.
+type GameResult struct {
+	Standings []Standing `json:"standings"`
+	// Moves played in the whole game.
+	Moves int `json:"moves"`
+	// Set when the host abandoned the round for a new one.
+	Aborted bool `json:"aborted"`
+	// Rounds played in the room so far, and the points across them.
+	Round       int                `json:"round"`
+	Leaderboard []LeaderboardEntry `json:"leaderboard"`
+}
+
+// RoundVote is sent by a player who wants a new round.
+type RoundVote struct {
+}
+
+// RoundVotes is broadcast whenever a player votes for a new round.
+type RoundVotes struct {
+	Votes  int `json:"votes"`
+	Needed int `json:"needed"`
+}
+
+// Leaderboard is sent empty to ask for the all-time leaderboard, and is
+// answered with the stats of every player, best first.
+type Leaderboard struct {
+	Players []PlayerStats `json:"players"`
+}
+
+// ServerShutdown is sent to everyone in a room just before the server closes
+// their connection to shut down.
+type ServerShutdown struct {
+	Reason string `json:"reason"`
+	// Set when the round in progress was saved, so players can rejoin it
+	// with their seat token once the server is back.
+	Resumable bool `json:"resumable"`
+}
+
+// playerList expects g.lock to be held until the list has been encoded, as
+// it shares the clients.
 func (g *Game) playerList() PlayersList {
 	clients := make([]*Client, 0, len(g.clients))
 	for c := range g.clients {
 		clients = append(clients, c)
 	}
 	pList := PlayersList{
-		Command: PlayersListCommand,
 		Players: clients,
 	}
 	return pList
 }
 
+// Rules returns the engine rules the game is played by.
+func (c GameConfig) Rules() engine.Rules {
+	rules := engine.Rules{
+		Size:      c.BoardSize,
+		WinLines:  c.WinLines,
+		Diagonals: c.Diagonals,
+	}
+	switch c.EndCondition {
+	case EndFirst:
+		rules.Finishers = 1
+	case EndTop:
+		rules.Finishers = c.Finishers
+	}
+	return rules
+}
+
+// publicConfig is the game config as players and spectators see it, without
+// the seed. It expects g.lock to be held.
+func (g *Game) publicConfig() GameConfig {
+	config := g.gameConfig()
+	config.Seed = 0
+	return config
+}
+
 func (g *Game) gameConfig() GameConfig {
 	return GameConfig{
-		Command:     GameConfigCommand,
 		IsLobbyMode: g.IsLobbyMode,
 		BoardSize:   g.BoardSize,
+		WinLines:    g.WinLines,
+		Diagonals:   g.Diagonals,
+		MaxPlayers:  g.MaxPlayers,
+
+		TurnTimeout:   g.TurnTimeout.Milliseconds(),
+		TimeoutPolicy: g.TimeoutPolicy,
+		EndCondition:  g.EndCondition,
+		Finishers:     g.Finishers,
+		Seed:          g.seed,
 	}
 }
@@ -0,0 +1,57 @@
+package bingo
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestBoardJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload interface{}
+		want    string
+	}{
+		{"board", PlayersBoard{Board: Board{{1, 2}, {3, 4}}}, `{"board":[[1,2],[3,4]]}`},
+		{"no board", PlayersBoard{}, `{"board":null}`},
+		{"crossed", GameState{Crossed: Numbers{7, 25}}, `{"crossed":[7,25],"moves":null}`},
+		{"nothing crossed", GameState{Crossed: Numbers{}}, `{"crossed":[],"moves":null}`},
+	}
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.payload)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, data, tt.want)
+		}
+		back := reflect.New(reflect.TypeOf(tt.payload))
+		if err := json.Unmarshal(data, back.Interface()); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := back.Elem().Interface(); !reflect.DeepEqual(got, tt.payload) {
+			t.Errorf("%s: read back %#v, want %#v", tt.name, got, tt.payload)
+		}
+	}
+}
+
+func TestBoardJSONReadsBase64(t *testing.T) {
+	// How journals wrote boards before they were sent as numbers.
+	var player JournalPlayer
+	if err := json.Unmarshal([]byte(`{"id":1,"name":"a","board":["AQI=","AwQ="]}`), &player); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Board{{1, 2}, {3, 4}}); !reflect.DeepEqual(player.Board, want) {
+		t.Errorf("got %v, want %v", player.Board, want)
+	}
+}
+
+func TestBoardJSONOutOfRange(t *testing.T) {
+	var board PlayersBoard
+	if err := json.Unmarshal([]byte(`{"board":[[1,256]]}`), &board); err == nil {
+		t.Error("a number above 255 was accepted")
+	}
+	if err := json.Unmarshal([]byte(`{"board":[[-1,2]]}`), &board); err == nil {
+		t.Error("a negative number was accepted")
+	}
+}
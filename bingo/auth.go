@@ -0,0 +1,198 @@
+package bingo
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/jayakrishnan-jayu/bin-go/utils"
+)
+
+var ErrNameTaken = errors.New("this name is already taken in the room")
+
+// Headers of the websocket handshake that carry a client's secrets, which
+// are kept out of the URL as proxies and access logs record it. The user
+// token goes in an Authorization header as a bearer token.
+const (
+	PasswordHeader = "X-Bingo-Password"
+	SeatHeader     = "X-Bingo-Seat"
+)
+
+// AuthHeader returns the handshake headers carrying the room password, the
+// user token and the seat token to resume, leaving out the empty ones.
+func AuthHeader(password, auth, seat string) http.Header {
+	header := make(http.Header)
+	if password != "" {
+		header.Set(PasswordHeader, password)
+	}
+	if auth != "" {
+		header.Set("Authorization", "Bearer "+auth)
+	}
+	if seat != "" {
+		header.Set(SeatHeader, seat)
+	}
+	return header
+}
+
+// bearerToken returns the token of the request's Authorization header.
+func bearerToken(r *http.Request) string {
+	const scheme = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if len(auth) < len(scheme) || !strings.EqualFold(auth[:len(scheme)], scheme) {
+		return ""
+	}
+	return auth[len(scheme):]
+}
+
+// seatToken returns the seat token a player resuming their seat sent.
+func seatToken(r *http.Request) string {
+	return r.Header.Get(SeatHeader)
+}
+
+// LoadUserTokens reads a file of "username token" lines, the users allowed
+// to join and the token each of them has to present. Blank lines and lines
+// starting with # are skipped.
+func LoadUserTokens(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	users := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected a username and a token", path, line)
+		}
+		if err := validName(fields[0]); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		users[fields[0]] = fields[1]
+	}
+	return users, scanner.Err()
+}
+
+func validName(name string) error {
+	if name == "" {
+		return fmt.Errorf("name must not be empty")
+	}
+	if len(name) > utils.MaxNameLength {
+		return fmt.Errorf("name must be at most %d bytes", utils.MaxNameLength)
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) || unicode.IsSpace(r) {
+			return fmt.Errorf("name must not contain spaces or control characters")
+		}
+	}
+	return nil
+}
+
+// secretsEqual compares secrets in constant time.
+func secretsEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// checkPassword reports whether the request carries password, if there is
+// one.
+func checkPassword(r *http.Request, password string) bool {
+	return password == "" || secretsEqual(r.Header.Get(PasswordHeader), password)
+}
+
+// checkUser checks the token of the user a request connects as, if only the
+// known users are let in.
+func checkUser(r *http.Request, users map[string]string) error {
+	if users == nil {
+		return nil
+	}
+	name := r.URL.Query().Get("name")
+	token, ok := users[name]
+	if name == "" || !ok || !secretsEqual(bearerToken(r), token) {
+		return fmt.Errorf("unknown user or wrong token")
+	}
+	return nil
+}
+
+// checkPassword reports whether the request carries the room password, if
+// the room has one.
+func (g *Game) checkPassword(r *http.Request) bool {
+	return checkPassword(r, g.Password)
+}
+
+// authenticate checks the name a player connects with, and their token if
+// the room only lets in known users. It returns the name, empty if the
+// player will pick one later, and the HTTP status to refuse them with.
+func (g *Game) authenticate(r *http.Request) (string, int, error) {
+	if err := checkUser(r, g.Users); err != nil {
+		return "", http.StatusUnauthorized, err
+	}
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		return "", http.StatusOK, nil
+	}
+	if err := validName(name); err != nil {
+		return "", http.StatusBadRequest, err
+	}
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	if g.nameTaken(name, nil) {
+		return "", http.StatusConflict, ErrNameTaken
+	}
+	return name, http.StatusOK, nil
+}
+
+// nameTaken reports whether a player other than c goes by name. Names
+// differing only in case count as the same. It expects g.lock to be held.
+func (g *Game) nameTaken(name string, c *Client) bool {
+	for other := range g.clients {
+		if other != c && strings.EqualFold(other.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueName returns name, numbered if another player already has it. It
+// expects g.lock to be held.
+func (g *Game) uniqueName(name string) string {
+	unique := name
+	for i := 2; g.nameTaken(unique, nil); i++ {
+		unique = fmt.Sprintf("%s-%d", name, i)
+	}
+	return unique
+}
+
+// setName gives c the name it asked for. Names are unique in a room, cannot
+// change once the game has started, and a name given when connecting is
+// kept for good.
+func (c *Client) setName(name string) error {
+	g := c.game
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if name == c.Name {
+		return nil
+	}
+	if c.nameLocked {
+		return newProtocolError(ErrorCodeInvalidName, "you joined as %s", c.Name)
+	}
+	if !g.IsLobbyMode {
+		return newProtocolError(ErrorCodeLobbyClosed, "names cannot be changed once the game has started")
+	}
+	if err := validName(name); err != nil {
+		return newProtocolError(ErrorCodeInvalidName, "%v", err)
+	}
+	if g.nameTaken(name, c) {
+		return newProtocolError(ErrorCodeNameTaken, "%s is already taken in this room", name)
+	}
+	c.Name = name
+	return nil
+}
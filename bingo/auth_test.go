@@ -0,0 +1,31 @@
+package bingo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckUserReadsBearerToken(t *testing.T) {
+	users := map[string]string{"alice": "s3cret"}
+	tests := []struct {
+		name   string
+		header http.Header
+		ok     bool
+	}{
+		{"right token", AuthHeader("", "s3cret", ""), true},
+		{"lower case scheme", http.Header{"Authorization": {"bearer s3cret"}}, true},
+		{"wrong token", AuthHeader("", "guess", ""), false},
+		{"no token", nil, false},
+		{"other scheme", http.Header{"Authorization": {"Basic s3cret"}}, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/ws/room?name=alice", nil)
+		for key, values := range tt.header {
+			r.Header[key] = values
+		}
+		if err := checkUser(r, users); (err == nil) != tt.ok {
+			t.Errorf("%s: got %v", tt.name, err)
+		}
+	}
+}
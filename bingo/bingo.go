@@ -1,28 +1,61 @@
 package bingo
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
+	"math/rand"
 	"net"
 	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/jayakrishnan-jayu/bin-go/bingo/engine"
+	"github.com/jayakrishnan-jayu/bin-go/utils"
 )
 
-var upgrader = websocket.Upgrader{}
-
 type Game struct {
+	ID          string
 	IsLobbyMode bool
 	BoardSize   uint8
+	WinLines    uint8
+	Diagonals   bool
+	MaxPlayers  int
+
+	EndCondition EndCondition
+	Finishers    int
+
+	TurnTimeout   time.Duration
+	TimeoutPolicy TimeoutPolicy
+	MaxTimeouts   int
+
+	MaxErrors int
+
+	// AI players the host can add, by level.
+	Agents map[string]AgentFactory
+
+	JournalDir string
+	Stats      StatsStore
+	SaveDir    string
+
+	Password  string
+	Users     map[string]string
+	Admission *Admission
+
+	ReconnectGrace time.Duration
+
 	playerIndex uint8
-	lock sync.RWMutex
+	lock        sync.RWMutex
 	// Registered clients.
 	clients map[*Client]bool
 
+	// Clients watching the game without playing.
+	spectators map[*Client]bool
+
 	// Inbound messages from the clients.
-	broadcast chan []byte
+	broadcast chan outgoing
 
 	// Inbound messages from the clients.
 	receive chan GameMove
@@ -33,313 +66,729 @@ type Game struct {
 	// Unregister requests from clients.
 	unregister chan *Client
 
+	// Websockets that have closed.
+	disconnect chan connection
+
+	// Held seats whose grace period has run out.
+	expire chan connection
+
 	// Input from Host
 	input chan string
 
-	// Score Index to print on the scoreboard
-	scoreIndex uint8
+	// The round being played. Only play changes it, apart from players
+	// leaving.
+	state engine.State
 
-	// Board values: true exists, false does not exist
-	values *[][]bool
+	// Seed of the round being played or about to be, each round's seed is
+	// derived from the last. rng draws the round's random moves.
+	seed int64
+	rng  *rand.Rand
+
+	// Journal of the round being played, nil if none is kept.
+	journal *journal
+
+	// Closed to abandon the round being played, and once it has ended.
+	round chan struct{}
+
+	// Set when a new round should start once every player has a board.
+	startPending bool
+
+	// Set once a player who is not a bot has joined.
+	joined bool
+	// When the room was created, rooms nobody joins are removed after
+	// utils.EmptyRoomGrace.
+	created time.Time
+
+	// Rounds played in this room and the points scored across them.
+	rounds      int
+	leaderboard map[uint8]*LeaderboardEntry
+
+	// The player whose turn it is and when it runs out. turn counts every
+	// turn played in the room, so moves can name the turn they were made for.
+	turn         uint64
+	turnPlayer   uint8
+	turnDeadline time.Time
+
+	// Sequence number of the last message sent to the room's clients.
+	seq uint64
+
+	// Closed when the room is torn down.
+	quit     chan struct{}
+	quitOnce sync.Once
+
+	// Set when the server is shutting down, and sent to every client as
+	// their connection is closed.
+	shutdownReason string
+
+	// Counts the connections still being written to. Set closing stops
+	// more from being counted once Shutdown may be waiting on them.
+	pumps   sync.WaitGroup
+	closing bool
 }
 
 func (game *Game) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// fmt.Println("new Connection")
-	if !game.IsLobbyMode {
-		http.Error(w, "This Server is not accepting anymore players", http.StatusForbidden)
+	if !checkProtocol(w, r) {
 		return
 	}
-
-	c, err := upgrader.Upgrade(w, r, nil)
+	if token := seatToken(r); token != "" {
+		// The seat token is proof enough of who the player is.
+		game.resume(w, r, token)
+		return
+	}
+	if !game.checkPassword(r) {
+		http.Error(w, "Wrong room password", http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Query().Get("watch") != "" {
+		game.spectate(w, r)
+		return
+	}
+	name, status, err := game.authenticate(r)
 	if err != nil {
-		log.Fatal("ServeHTTP: ", err)
+		http.Error(w, err.Error(), status)
+		return
 	}
-
-	ipnet, ok := c.LocalAddr().(*net.TCPAddr)
-	if !ok {
-		log.Fatal("ServeHTTP: Could not find IP")
+	info := game.Info()
+	if !info.IsLobbyMode {
+		http.Error(w, "This Server is not accepting anymore players", http.StatusForbidden)
+		return
 	}
-	game.playerIndex += 1
-	client := &Client{
-		Id:   game.playerIndex,
-		Ip:   ipnet.IP,
-		Conn: c,
-		game: game,
-		Send: make(chan []byte, 256),
-		score: 0,
-		scoreIndex: 0,
+	if game.MaxPlayers > 0 && info.Players >= game.MaxPlayers {
+		http.Error(w, "This room is full", http.StatusServiceUnavailable)
+		return
 	}
-	// fmt.Println("new user")
 
-	game.register <- client
-
-	go client.writePump()
-	go client.readPump()
+	conn, err := game.upgrade(w, r)
+	if err != nil {
+		log.Println("ServeHTTP: ", err)
+		return
+	}
 
-	client.requestPlayerName()
-	client.sendPlayerID()
-	client.sendGameConfig()
-	client.requestGeneratedBoard()
+	if _, err := game.Join(conn, remoteIP(r), name); err != nil {
+		log.Println("ServeHTTP: ", err)
+		closeWith(conn, websocket.ClosePolicyViolation, err.Error())
+	}
 }
 
 func (g *Game) broadcastPlayerlist() {
-	output, err := json.Marshal(g.playerList())
-	if err != nil {
-		log.Fatal("broadcastPlayerlist: ", err)
-		return
-	}
-	g.broadcast <- output
+	g.lock.RLock()
+	message := g.encode(PlayersListCommand, g.playerList())
+	g.lock.RUnlock()
+	g.send(message)
 }
 
 func (g *Game) broadcastGameMove(move *GameMove) {
 	move.Name = move.Author.Name
-	output, err := json.Marshal(move)
-	if err != nil {
-		log.Fatal("broadcastPlayerlist: ", err)
-		return
+	g.send(g.encode(GameMoveCommand, move))
+}
+
+// timeLeft returns the milliseconds until deadline, or 0 if there is no
+// deadline.
+func timeLeft(deadline time.Time) int64 {
+	if deadline.IsZero() {
+		return 0
+	}
+	left := time.Until(deadline).Milliseconds()
+	if left < 1 {
+		left = 1
 	}
-	g.broadcast <- output
+	return left
 }
 
-func (g *Game) sendGameStatus(playerId uint8) {
+func (g *Game) sendGameStatus(playerId uint8, turn uint64, deadline time.Time) {
 	cmd := GameStatus{
-		Command:  GameStatusCommand,
 		PlayerId: playerId,
+		Turn:     turn,
+		TimeLeft: timeLeft(deadline),
 	}
-	output, err := json.Marshal(cmd)
-	if err != nil {
-		log.Fatal("requestClientName: ", err)
+	g.sendTo(players, g.encode(GameStatusCommand, cmd))
+}
+
+func (g *Game) broadcastTimeout(c *Client, action TimeoutPolicy) {
+	cmd := GameTimeout{
+		PlayerId: c.Id,
+		Name:     c.Name,
+		Action:   action,
 	}
-	g.broadcast <- output
+	g.send(g.encode(GameTimeoutCommand, cmd))
 }
 
-func (c *Client) requestPlayerName() {
-	cmd := RequestCommand{Command: PlayerNameCommand}
-	output, err := json.Marshal(cmd)
+// encode wraps a message for the room's clients in an envelope, numbering
+// it after the last message the room sent.
+func (g *Game) encode(t MessageType, payload interface{}) []byte {
+	output, err := Encode(t, atomic.AddUint64(&g.seq, 1), payload)
 	if err != nil {
-		log.Fatal("requestClientName: ", err)
+		log.Fatal("encode: ", err)
 	}
-	c.Send <- output
+	return output
 }
 
-func (c *Client) sendPlayerID() {
-	cmd := PlayerID{
-		Command: PlayerIDCommand,
-		ID:      c.Id,
+// audience selects who a broadcast message is delivered to.
+type audience int
+
+const (
+	everyone audience = iota
+	players
+	spectators
+)
+
+type outgoing struct {
+	message []byte
+	to      audience
+	// Set to deliver the message to this player alone.
+	client *Client
+}
+
+// send queues a message for every client and spectator unless the room has
+// been closed.
+func (g *Game) send(message []byte) {
+	g.sendTo(everyone, message)
+}
+
+func (g *Game) sendTo(to audience, message []byte) {
+	select {
+	case g.broadcast <- outgoing{message: message, to: to}:
+	case <-g.quit:
 	}
-	output, err := json.Marshal(cmd)
-	if err != nil {
-		log.Fatal("requestGenerateBoard: ", err)
+}
+
+// sendToPlayer queues a message for c behind the broadcasts queued before
+// it, unless the room has been closed.
+func (g *Game) sendToPlayer(c *Client, message []byte) {
+	select {
+	case g.broadcast <- outgoing{message: message, client: c}:
+	case <-g.quit:
 	}
-	c.Send <- output
 }
 
-func (c *Client) requestGeneratedBoard() {
-	cmd := RequestCommand{Command: PlayerBoardCommand}
-	output, err := json.Marshal(cmd)
-	if err != nil {
-		log.Fatal("requestGenerateBoard: ", err)
+// sendToClient queues a message for a single client or spectator, dropping
+// it if they have already left the room.
+func (g *Game) sendToClient(c *Client, message []byte) {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	_, ok := g.clients[c]
+	if c.spectator {
+		_, ok = g.spectators[c]
+	}
+	if !ok || !c.Connected {
+		return
+	}
+	select {
+	case c.Send <- message:
+	default:
+	}
+}
+
+func (c *Client) requestPlayerName() {
+	c.Send <- c.game.encode(PlayerNameCommand, nil)
+}
+
+func (c *Client) sendPlayerID() {
+	cmd := PlayerID{
+		ID:    c.Id,
+		Token: c.token,
 	}
-	c.Send <- output
+	c.Send <- c.game.encode(PlayerIDCommand, cmd)
+}
+
+func (c *Client) sendBoard() {
+	c.Send <- c.game.encode(PlayerBoardCommand, PlayersBoard{Board: c.board})
 }
 
 func (c *Client) sendGameConfig() {
-	output, err := json.Marshal(c.game.gameConfig())
-	if err != nil {
-		log.Fatal("sendGameConfig:", err)
-		return
+	c.Send <- c.game.encode(GameConfigCommand, c.game.publicConfig())
+}
+
+func (c *Client) sendError(err *ProtocolError) {
+	cmd := Error{Code: err.Code, Message: err.Message}
+	c.game.sendToClient(c, c.game.encode(ErrorCommand, cmd))
+}
+
+// sendGameStatus tells only this client whose turn it is.
+func (c *Client) sendGameStatus(playerId uint8, turn uint64, deadline time.Time) {
+	cmd := GameStatus{
+		PlayerId: playerId,
+		Turn:     turn,
+		TimeLeft: timeLeft(deadline),
 	}
-	c.Send <- output
+	c.game.sendToClient(c, c.game.encode(GameStatusCommand, cmd))
 }
 
-func (c *Client) sendGameScoreIndex() {
-	cmd := GameScoreIndex{Command: GameScoreIndexCommand, Score: c.scoreIndex}
-	output, err := json.Marshal(cmd)
-	if err != nil {
-		log.Fatal("requestGenerateBoard: ", err)
+// sendGameScoreIndex tells a player the place they finished in.
+func (g *Game) sendGameScoreIndex(id uint8, place uint8) {
+	g.lock.RLock()
+	var client *Client
+	for c := range g.clients {
+		if c.Id == id {
+			client = c
+		}
+	}
+	g.lock.RUnlock()
+	if client != nil {
+		g.sendToClient(client, g.encode(GameScoreIndexCommand, GameScoreIndex{Score: place}))
 	}
-	c.Send <- output
 }
 
-func New(serverIp net.IP) *Game {
+func New(serverIp net.IP, options GameOptions) *Game {
 	game := &Game{
 		IsLobbyMode: true,
-		BoardSize:   2,
-		broadcast:   make(chan []byte),
+		BoardSize:   options.BoardSize,
+		WinLines:    options.WinLines,
+		Diagonals:   options.Diagonals,
+		MaxPlayers:  options.MaxPlayers,
+
+		EndCondition: options.EndCondition,
+		Finishers:    options.Finishers,
+
+		TurnTimeout:   options.TurnTimeout,
+		TimeoutPolicy: options.TimeoutPolicy,
+		MaxTimeouts:   options.MaxTimeouts,
+
+		MaxErrors: options.MaxErrors,
+		Agents:    options.Agents,
+
+		JournalDir: options.JournalDir,
+		Stats:      options.Stats,
+		SaveDir:    options.SaveDir,
+
+		Password:  options.Password,
+		Users:     options.Users,
+		Admission: options.Admission,
+
+		ReconnectGrace: options.ReconnectGrace,
+
+		created:     time.Now(),
+		broadcast:   make(chan outgoing),
 		receive:     make(chan GameMove),
 		register:    make(chan *Client),
 		unregister:  make(chan *Client),
+		disconnect:  make(chan connection),
+		expire:      make(chan connection),
 		clients:     make(map[*Client]bool),
+		spectators:  make(map[*Client]bool),
+		leaderboard: make(map[uint8]*LeaderboardEntry),
 		input:       make(chan string),
-		scoreIndex: 1,
+		quit:        make(chan struct{}),
 	}
-	
-	values := make([][]bool, game.BoardSize)
-	for i := range values {
-		values[i] = make([]bool, game.BoardSize)
-        for j := range values[i] {
-            values[i][j] = true
-        }
+	if game.seed = options.Seed; game.seed == 0 {
+		game.seed = time.Now().UnixNano()
 	}
-	game.values = &values
 	return game
 }
-func (g *Game) readInput() {
 
-	for {
-		var u string
-		fmt.Scanf("%s\n", &u)
-		g.input <- u
-	}
-}
-
-func (g *Game) updateTable(n uint8) {
-	n -= 1
-	i := n / g.BoardSize
-	j := n % g.BoardSize
-	(*g.values)[i][j] = false 
-}
-
-func (g *Game) isCrossed(n uint8) bool {
-	n -= 1
-	i := n / g.BoardSize
-	j := n % g.BoardSize
-	return !(*g.values)[i][j]
-}
-
-func (g *Game) computePlayerScore(board *[][]uint8) (rows, cols, diags uint8){
-	n := len(*board)
-    diagCrossed := true
-    inverseDiagCrossed := true
-    for i := 0; i < n; i++ {
-        rowCrossed := true
-        colCrossed := true
-        for j := 0; j < n; j++ {
-            rowCrossed = rowCrossed && g.isCrossed((*board)[i][j])
-            colCrossed = colCrossed && g.isCrossed((*board)[j][i])
-            if i == j {
-                diagCrossed = diagCrossed && g.isCrossed((*board)[i][j])
-                inverseDiagCrossed = inverseDiagCrossed && g.isCrossed((*board)[i][n-1-i])
-            }
-        }
-        if rowCrossed {
-            rows++
-        }
-        if colCrossed {
-            cols++
-        }
-    }
-	if diagCrossed {
-		diags++
-	}
-	if inverseDiagCrossed {
-		diags++
-	}
-    return
-}
-
-func (g* Game) renderScoreBoard() {
-	scoreIndexChanged := false
-	fmt.Println("\n\nStart")
+// Input passes a host command to the room.
+func (g *Game) Input(cmd string) {
+	select {
+	case g.input <- cmd:
+	case <-g.quit:
+	}
+}
+
+// Stop tears the room down and disconnects every client.
+func (g *Game) Stop() {
+	g.quitOnce.Do(func() { close(g.quit) })
+}
+
+// Finished reports whether every player has left the room. Rooms only bots
+// have played in are not finished, and neither are rooms in a round, which
+// the bots left behind get to play out. A room nobody has joined is
+// finished once it has stood empty for utils.EmptyRoomGrace.
+func (g *Game) Finished() bool {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	if !g.joined {
+		return len(g.clients) == 0 && len(g.spectators) == 0 && time.Since(g.created) >= utils.EmptyRoomGrace
+	}
+	if !g.IsLobbyMode {
+		return false
+	}
 	for c := range g.clients {
-		fmt.Printf("%s - %d\n", c.Name, c.score)
-		if c.score < g.BoardSize {
-			
-			row, col, diag := g.computePlayerScore(c.board)
-			
-			c.score = row+col+diag
-			fmt.Printf("New Score %d\n", c.score)
-			if c.score >= g.BoardSize {
-				scoreIndexChanged = true
-				c.scoreIndex = g.scoreIndex
-				fmt.Printf("Score Index %d\n", c.scoreIndex)
-				c.sendGameScoreIndex()
+		if !c.Bot {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *Game) Info() RoomInfo {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	return RoomInfo{
+		ID:          g.ID,
+		Players:     len(g.clients),
+		Spectators:  len(g.spectators),
+		IsLobbyMode: g.IsLobbyMode,
+	}
+}
+
+// deal draws the board c plays the next round with from the round's seed.
+// It expects g.lock to be held.
+func (g *Game) deal(c *Client) {
+	c.board = engine.NewBoard(int(g.BoardSize), engine.NewRand(g.seed, c.Id))
+}
+
+// randomMove picks a random number that has not been crossed yet.
+func (g *Game) randomMove() uint8 {
+	g.lock.RLock()
+	open := g.state.Open()
+	g.lock.RUnlock()
+	return open[g.rng.Intn(len(open))]
+}
+
+// isPlaying reports whether c is still in the round and has not finished.
+func (g *Game) isPlaying(c *Client) bool {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	p, ok := g.state.Player(c.Id)
+	return ok && p.Playing()
+}
+
+// playTurn waits for a legal move from c and applies it. Illegal moves are
+// reported back to c and its turn stays open until TurnTimeout runs out. It
+// returns false if the room was closed or the round abandoned while waiting.
+func (g *Game) playTurn(c *Client) bool {
+	var deadline time.Time
+	var timeout <-chan time.Time
+	if g.TurnTimeout > 0 {
+		deadline = time.Now().Add(g.TurnTimeout)
+		timer := time.NewTimer(g.TurnTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	g.lock.Lock()
+	g.turn++
+	turn := g.turn
+	g.turnPlayer = c.Id
+	g.turnDeadline = deadline
+	round := g.round
+	g.lock.Unlock()
+	g.sendGameStatus(c.Id, turn, deadline)
+	g.broadcastSpectatorView()
+	for {
+		var gameMove GameMove
+		select {
+		case gameMove = <-g.receive:
+		case <-timeout:
+			return g.handleTimeout(c, turn)
+		case <-c.gone:
+			return true
+		case <-round:
+			return false
+		case <-g.quit:
+			return false
+		}
+		if gameMove.Author != c || gameMove.Turn != turn {
+			// A late move from a turn that has already timed out.
+			continue
+		}
+		if err := g.applyMove(&gameMove); err != nil {
+			c.sendError(moveError(err))
+			c.sendGameStatus(c.Id, turn, deadline)
+			continue
+		}
+		c.timeouts = 0
+		return true
+	}
+}
+
+// handleTimeout applies the TimeoutPolicy to c after it ran out of time on
+// turn. It returns false if the room was closed.
+func (g *Game) handleTimeout(c *Client, turn uint64) bool {
+	c.timeouts++
+	switch g.TimeoutPolicy {
+	case TimeoutRandom:
+		g.applyMove(&GameMove{
+			Change: g.randomMove(),
+			Turn:   turn,
+			Auto:   true,
+			Author: c,
+		})
+		return true
+	case TimeoutEject:
+		if c.timeouts >= g.MaxTimeouts {
+			c.sendError(newProtocolError(ErrorCodeTimedOut, "removed after %d missed turns", c.timeouts))
+			g.broadcastTimeout(c, TimeoutEject)
+			fmt.Printf("%s removed after %d missed turns\n", c.Name, c.timeouts)
+			select {
+			case g.unregister <- c:
+			case <-g.quit:
+				return false
 			}
+			return true
 		}
-		
 	}
-	if scoreIndexChanged {
-		g.scoreIndex +=1
-		scoreIndexChanged = false
+	g.broadcastTimeout(c, TimeoutSkip)
+	fmt.Printf("%s skipped\n", c.Name)
+	return true
+}
+
+// applyMove plays a move through the engine and announces what it caused.
+func (g *Game) applyMove(gameMove *GameMove) error {
+	g.lock.Lock()
+	state, events, err := g.state.Apply(engine.Move{
+		Player: gameMove.Author.Id,
+		Number: gameMove.Change,
+		Auto:   gameMove.Auto,
+	})
+	if err != nil {
+		g.lock.Unlock()
+		return err
 	}
-	// RenderServerBoard(&g.clients)
+	g.state = state
+	g.journal.write(JournalEntry{Type: JournalMove, Move: &MoveRecord{
+		PlayerId: gameMove.Author.Id,
+		Number:   gameMove.Change,
+		Auto:     gameMove.Auto,
+		Turn:     gameMove.Turn,
+	}})
+	g.lock.Unlock()
+
+	for _, event := range events {
+		switch e := event.(type) {
+		case engine.Crossed:
+			g.broadcastGameMove(gameMove)
+			fmt.Printf("%s update: %d\n", gameMove.Author.Name, e.Number)
+		case engine.LinesCompleted:
+			fmt.Printf("%s has %d lines\n", g.playerName(e.Player), e.Lines)
+		case engine.Finished:
+			g.sendGameScoreIndex(e.Player, e.Place)
+			fmt.Printf("%s finished #%d\n", g.playerName(e.Player), e.Place)
+		}
+	}
+	g.broadcastSpectatorView()
+	return nil
 }
 
-func (g *Game) play() {
+func (g *Game) playerName(id uint8) string {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	p, _ := g.state.Player(id)
+	return p.Name
+}
+
+// play takes the players through their turns, in the order of the round's
+// state, starting with next. A next of 0 starts with the first player.
+func (g *Game) play(next uint8) {
 	ClearTerminal()
-	clients := make([]*Client, 0, len(g.clients))
+	g.lock.RLock()
+	byID := make(map[uint8]*Client, len(g.clients))
 	for c := range g.clients {
-		clients = append(clients, c)
+		byID[c.Id] = c
 	}
-	for {
-		for _, c := range clients {
-			g.lock.RLock()
-			if _, ok := g.clients[c]; ok && c.scoreIndex <= 0{
-				defer g.lock.RUnlock()
-				g.sendGameStatus(c.Id)
-				gameMove := <-g.receive
-				if gameMove.Author != c {
-					log.Fatal("play: gameMove author assertion failed")
+	clients := make([]*Client, 0, len(g.state.Players))
+	start := 0
+	for _, p := range g.state.Players {
+		if c, ok := byID[p.ID]; ok {
+			if c.Id == next {
+				start = len(clients)
+			}
+			clients = append(clients, c)
+		}
+	}
+	seed := g.seed
+	g.lock.RUnlock()
+	fmt.Printf("Playing round with seed %d\n", seed)
+	for !g.isOver() {
+		for _, c := range clients[start:] {
+			if g.isOver() {
+				break
+			}
+			if !g.isPlaying(c) {
+				continue
+			}
+			if !g.playTurn(c) {
+				select {
+				case <-g.quit:
+				default:
+					g.endGame(true)
 				}
-				g.updateTable(gameMove.Change)
-				g.renderScoreBoard()
-				fmt.Printf("%s update: %d\n", gameMove.Author.Name, gameMove.Change)
-
+				return
 			}
 		}
+		start = 0
+	}
+	select {
+	case <-g.quit:
+		// Players removed as the room closed do not end the round.
+		return
+	default:
+	}
+	g.endGame(false)
+}
+
+func (g *Game) isOver() bool {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	return g.state.Over()
+}
+
+// removeClient expects g.lock to be held.
+func (g *Game) removeClient(client *Client) {
+	if _, ok := g.clients[client]; ok {
+		// writePump sends a close message and closes the connection
+		// once the queued messages have been written.
+		if client.Connected {
+			close(client.Send)
+		}
+		close(client.gone)
+		delete(g.clients, client)
+		if !g.IsLobbyMode {
+			g.state = g.state.Leave(client.Id)
+			g.journal.write(JournalEntry{Type: JournalLeave, PlayerId: client.Id})
+		}
+	}
+}
+
+func (g *Game) renderLobby() {
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	g.playerList().RenderLobby()
+	fmt.Printf("Enter s %s to start game\n", g.ID)
+	if g.rounds > 0 {
+		fmt.Printf("Enter n %s to start a new round once every player has a board\n", g.ID)
 	}
 }
 
 func (g *Game) Run() {
-	go g.readInput()
+	defer func() {
+		g.lock.Lock()
+		for client := range g.clients {
+			g.removeClient(client)
+		}
+		for spectator := range g.spectators {
+			g.removeSpectator(spectator)
+		}
+		g.closeJournal(nil)
+		g.lock.Unlock()
+	}()
 	for {
 		select {
+		case <-g.quit:
+			return
 		case client := <-g.register:
-			fmt.Println("got user to regiser", client)
+			g.lock.Lock()
 			g.clients[client] = true
-			g.playerList().RenderLobby()
-			fmt.Println("Enter s to start game")
-			// fmt.Println("regiseterd new user")
+			lobby := g.IsLobbyMode
+			g.lock.Unlock()
+			if lobby {
+				g.renderLobby()
+			}
 		case client := <-g.unregister:
-			for {
-				g.lock.Lock()
-				defer g.lock.Unlock()
-				if _, ok := g.clients[client]; ok {
-					close(client.Send)
-					client.Conn.Close()
-					delete(g.clients, client)
-				}
-				if len(g.unregister) > 0 {
-					client = <-g.unregister
-					continue
-				}
-				if len(g.clients) > 0 {
-					go g.broadcastPlayerlist()
-				}
+			g.lock.Lock()
+			g.removeClient(client)
+			g.maybeStartRound()
+			remaining, lobby := len(g.clients), g.IsLobbyMode
+			g.lock.Unlock()
+			if remaining > 0 {
+				go g.broadcastPlayerlist()
+			}
+			if lobby {
+				g.renderLobby()
+			}
+		case dc := <-g.disconnect:
+			g.lock.Lock()
+			client := dc.client
+			if client.spectator {
+				g.removeSpectator(client)
+				g.lock.Unlock()
+				break
+			}
+			_, ok := g.clients[client]
+			if !ok || !client.Connected || client.conn != dc.conn {
+				g.lock.Unlock()
 				break
 			}
-			g.playerList().RenderLobby()
-			fmt.Println("Enter s to start game")
+			if g.shouldHoldSeat(client) {
+				g.holdSeat(client, g.ReconnectGrace)
+				fmt.Printf("%s disconnected, holding their seat for %s\n", client.Name, g.ReconnectGrace)
+			} else {
+				g.removeClient(client)
+			}
+			remaining, lobby := len(g.clients), g.IsLobbyMode
+			g.lock.Unlock()
+			if remaining > 0 {
+				go g.broadcastPlayerlist()
+			}
+			if lobby {
+				g.renderLobby()
+			}
+		case dc := <-g.expire:
+			g.lock.Lock()
+			client := dc.client
+			_, ok := g.clients[client]
+			if ok && !client.Connected && client.conn == dc.conn {
+				g.removeClient(client)
+				fmt.Printf("%s did not rejoin in time\n", client.Name)
+			}
+			remaining := len(g.clients)
+			g.lock.Unlock()
+			if ok && remaining > 0 {
+				go g.broadcastPlayerlist()
+			}
 
 		// case message := <-g.receive:
 		// fmt.Println(message)
 
-		case message := <-g.broadcast:
-			for client := range g.clients {
-				select {
-				case client.Send <- message:
-				default:
-					close(client.Send)
-					delete(g.clients, client)
+		case out := <-g.broadcast:
+			g.lock.Lock()
+			if c := out.client; c != nil {
+				if _, ok := g.clients[c]; ok && c.Connected {
+					select {
+					case c.Send <- out.message:
+					default:
+						g.removeClient(c)
+					}
+				}
+				g.lock.Unlock()
+				break
+			}
+			if out.to != spectators {
+				for client := range g.clients {
+					if !client.Connected {
+						continue
+					}
+					select {
+					case client.Send <- out.message:
+					default:
+						g.removeClient(client)
+					}
+				}
+			}
+			if out.to != players {
+				for spectator := range g.spectators {
+					select {
+					case spectator.Send <- out.message:
+					default:
+						g.removeSpectator(spectator)
+					}
 				}
 			}
+			g.lock.Unlock()
 		case cmd := <-g.input:
-			switch cmd {
+			fields := strings.Fields(cmd)
+			if len(fields) == 0 {
+				break
+			}
+			switch fields[0] {
+			case "addbot":
+				if len(fields) < 2 {
+					fmt.Println("usage: addbot <level> [room]")
+					break
+				}
+				if err := g.addAgent(fields[1]); err != nil {
+					fmt.Printf("addbot %s: %v\n", fields[1], err)
+					break
+				}
+				g.renderLobby()
 			case "s":
+				g.lock.Lock()
 				if g.IsLobbyMode {
-					g.IsLobbyMode = false
-					go g.play()
+					g.startRound()
 				}
+				g.lock.Unlock()
+			case "n":
+				g.newRound()
 			}
 		}
 	}
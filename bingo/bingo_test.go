@@ -0,0 +1,138 @@
+package bingo
+
+import (
+	"bytes"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jayakrishnan-jayu/bin-go/utils"
+)
+
+// pipePlayer plays a seat from the remote end of a Pipe, always crossing
+// the smallest number left.
+type pipePlayer struct {
+	conn    PlayerConn
+	id      uint8
+	board   Board
+	crossed map[uint8]bool
+	seq     uint64
+}
+
+func (p *pipePlayer) send(t *testing.T, mt MessageType, payload interface{}) {
+	output, err := Encode(mt, atomic.AddUint64(&p.seq, 1), payload)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	p.conn.Send(output)
+}
+
+// play answers the room until the round ends, and hands on its result.
+func (p *pipePlayer) play(t *testing.T, size int, results chan<- GameResult) {
+	p.crossed = make(map[uint8]bool)
+	for {
+		frame, err := p.conn.Receive()
+		if err != nil {
+			return
+		}
+		for _, message := range bytes.Split(frame, utils.Newline) {
+			_, payload, err := Decode(message)
+			if err != nil {
+				t.Error(err)
+				continue
+			}
+			switch msg := payload.(type) {
+			case *PlayerID:
+				p.id = msg.ID
+			case *PlayersBoard:
+				p.board = msg.Board
+			case *GameMove:
+				p.crossed[msg.Change] = true
+			case *GameStatus:
+				if msg.PlayerId != p.id {
+					break
+				}
+				for n := uint8(1); int(n) <= size*size; n++ {
+					if !p.crossed[n] {
+						p.send(t, GameMoveCommand, GameMove{Change: n, Turn: msg.Turn})
+						break
+					}
+				}
+			case *Error:
+				t.Errorf("player %d: %s", p.id, msg.Message)
+			case *GameResult:
+				results <- *msg
+				return
+			}
+		}
+	}
+}
+
+// hasLine reports whether a row or column of board is fully crossed.
+func hasLine(board Board, crossed map[uint8]bool) bool {
+	for i := range board {
+		row, column := true, true
+		for j := range board {
+			row = row && crossed[board[i][j]]
+			column = column && crossed[board[j][i]]
+		}
+		if row || column {
+			return true
+		}
+	}
+	return false
+}
+
+func TestGameOverPipes(t *testing.T) {
+	options := DefaultGameOptions
+	options.BoardSize = 3
+	options.WinLines = 1
+	options.Diagonals = false
+	options.Seed = 7
+	g := New(nil, options)
+	go g.Run()
+	defer g.Stop()
+
+	results := make(chan GameResult, 2)
+	players := []*pipePlayer{}
+	for _, name := range []string{"ann", "bob"} {
+		local, remote := Pipe()
+		defer remote.Close()
+		if _, err := g.Join(local, nil, name); err != nil {
+			t.Fatal(err)
+		}
+		p := &pipePlayer{conn: remote}
+		players = append(players, p)
+		go p.play(t, int(options.BoardSize), results)
+	}
+	g.Input("s")
+
+	var result GameResult
+	for range players {
+		select {
+		case result = <-results:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the round did not end")
+		}
+	}
+	if result.Aborted || result.Round != 1 {
+		t.Errorf("got round %d, aborted %v, want round 1 played out", result.Round, result.Aborted)
+	}
+	if len(result.Standings) != len(players) {
+		t.Fatalf("got %d standings, want %d", len(result.Standings), len(players))
+	}
+	if result.Standings[0].Rank != 1 || !result.Standings[0].Finished {
+		t.Errorf("nobody won: %+v", result.Standings)
+	}
+	for _, p := range players {
+		if len(p.crossed) != result.Moves {
+			t.Errorf("player %d saw %d moves, the result has %d", p.id, len(p.crossed), result.Moves)
+		}
+		for _, s := range result.Standings {
+			if s.Id == p.id && s.Finished != hasLine(p.board, p.crossed) {
+				t.Errorf("player %d finished %v with board %v and %d moves", p.id, s.Finished, p.board, result.Moves)
+			}
+		}
+	}
+}
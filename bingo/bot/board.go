@@ -0,0 +1,98 @@
+package bot
+
+// position is the row and column of a number on a board.
+type position struct {
+	row, col int
+}
+
+// layout indexes a board by number and counts the crossed cells on each of
+// its lines. Rows come first, then columns, then the two diagonals.
+type layout struct {
+	size      int
+	diagonals bool
+	// Indexed by number, 0 is not on the board.
+	positions []position
+	counts    []int
+}
+
+func newLayout(board [][]uint8, crossed map[uint8]bool, diagonals bool) *layout {
+	l := &layout{
+		size:      len(board),
+		diagonals: diagonals,
+		positions: make([]position, len(board)*len(board)+1),
+		counts:    make([]int, 2*len(board)+2),
+	}
+	for i, row := range board {
+		for j, n := range row {
+			l.positions[n] = position{i, j}
+			if crossed[n] {
+				l.cross(n)
+			}
+		}
+	}
+	return l
+}
+
+// lines stores the indexes of the lines running through n in lines and
+// returns how many there are.
+func (l *layout) lines(n uint8, lines *[4]int) int {
+	if int(n) >= len(l.positions) || n == 0 {
+		return 0
+	}
+	p := l.positions[n]
+	lines[0], lines[1] = p.row, l.size+p.col
+	count := 2
+	if l.diagonals {
+		if p.row == p.col {
+			lines[count] = 2 * l.size
+			count++
+		}
+		if p.row+p.col == l.size-1 {
+			lines[count] = 2*l.size + 1
+			count++
+		}
+	}
+	return count
+}
+
+func (l *layout) cross(n uint8) {
+	var lines [4]int
+	for _, line := range lines[:l.lines(n, &lines)] {
+		l.counts[line]++
+	}
+}
+
+// gain is how much crossing a number advances a board.
+type gain struct {
+	// Lines the number completes.
+	completed int
+	// Grows with the square of the crossed cells on each line, so numbers
+	// on nearly complete lines are worth more.
+	progress int
+}
+
+func (g gain) less(other gain) bool {
+	if g.completed != other.completed {
+		return g.completed < other.completed
+	}
+	return g.progress < other.progress
+}
+
+// value folds a gain into one number, a completed line being worth more
+// than any progress.
+func (g gain) value(size int) float64 {
+	return float64(g.completed*size*size*4 + g.progress)
+}
+
+func (l *layout) gain(n uint8) gain {
+	var g gain
+	var lines [4]int
+	for _, line := range lines[:l.lines(n, &lines)] {
+		k := l.counts[line]
+		if k+1 == l.size {
+			g.completed++
+		}
+		g.progress += 2*k + 1
+	}
+	return g
+}
@@ -0,0 +1,161 @@
+// Package bot plays bingo without a human at the keyboard.
+package bot
+
+import (
+	"log"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/jayakrishnan-jayu/bin-go/bingo"
+)
+
+// DefaultThink is how long bots wait before moving unless told otherwise.
+const DefaultThink = time.Second
+
+// Sender delivers a message from the bot to the server.
+type Sender func(t bingo.MessageType, payload interface{})
+
+type Options struct {
+	Strategy Strategy
+	// How long the bot waits before making its move.
+	Think time.Duration
+	// Whether the bot votes for a new round after every game.
+	Vote bool
+	// Source of the bot's random choices, nil for one seeded from the
+	// clock.
+	Rand *rand.Rand
+}
+
+// Bot plays one seat. It is handed every message the server sends the seat,
+// in order, and answers through its Sender.
+type Bot struct {
+	Name    string
+	options Options
+	send    Sender
+	rng     *rand.Rand
+
+	id      uint8
+	config  bingo.GameConfig
+	players map[uint8]string
+	board   [][]uint8
+	crossed map[uint8]bool
+	history []Move
+}
+
+// Levels are the AI players the host can add to a room with addbot.
+var Levels = map[string]Options{
+	"easy": {Strategy: Random{}, Think: DefaultThink},
+	"hard": {Strategy: Defensive{Samples: 200}, Think: DefaultThink},
+}
+
+// Agents returns a factory for each of the Levels, for bingo.GameOptions.
+func Agents() map[string]bingo.AgentFactory {
+	agents := make(map[string]bingo.AgentFactory, len(Levels))
+	for level, options := range Levels {
+		options := options
+		agents[level] = func(name string, rng *rand.Rand, send func(bingo.MessageType, interface{})) bingo.Agent {
+			options := options
+			options.Rand = rng
+			return New(name, options, send)
+		}
+	}
+	return agents
+}
+
+func New(name string, options Options, send Sender) *Bot {
+	if options.Strategy == nil {
+		options.Strategy = Random{}
+	}
+	if options.Rand == nil {
+		options.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return &Bot{
+		Name:    name,
+		options: options,
+		send:    send,
+		rng:     options.Rand,
+		players: make(map[uint8]string),
+		crossed: make(map[uint8]bool),
+	}
+}
+
+// Handle reacts to a decoded message from the server.
+func (b *Bot) Handle(payload interface{}) {
+	switch msg := payload.(type) {
+	case *bingo.PlayerName:
+		b.send(bingo.PlayerNameCommand, bingo.PlayerName{Name: b.Name})
+	case *bingo.PlayerID:
+		b.id = msg.ID
+	case *bingo.PlayersList:
+		for id := range b.players {
+			delete(b.players, id)
+		}
+		for _, c := range msg.Players {
+			b.players[c.Id] = c.Name
+		}
+	case *bingo.GameConfig:
+		b.config = *msg
+	case *bingo.PlayersBoard:
+		// The board the server dealt us, or sent back when we resume.
+		if msg.Board != nil {
+			b.board = msg.Board
+		}
+	case *bingo.GameStatus:
+		if msg.PlayerId != b.id || b.board == nil {
+			break
+		}
+		move := bingo.GameMove{
+			Change: b.options.Strategy.Choose(b.state(), b.rng),
+			Turn:   msg.Turn,
+		}
+		time.AfterFunc(b.options.Think, func() {
+			b.send(bingo.GameMoveCommand, move)
+		})
+	case *bingo.GameMove:
+		b.cross(Move{Player: msg.Name, Number: msg.Change, Auto: msg.Auto})
+	case *bingo.GameState:
+		for _, move := range msg.Moves {
+			b.cross(Move{Player: move.Name, Number: move.Change, Auto: move.Auto})
+		}
+	case *bingo.GameResult:
+		b.board = nil
+		b.crossed = make(map[uint8]bool)
+		b.history = nil
+		if b.options.Vote {
+			b.send(bingo.RoundVoteCommand, bingo.RoundVote{})
+		}
+	case *bingo.Error:
+		log.Printf("%s: server error: %s\n", b.Name, msg.Message)
+	}
+}
+
+func (b *Bot) cross(move Move) {
+	if b.crossed[move.Number] {
+		return
+	}
+	b.crossed[move.Number] = true
+	b.history = append(b.history, move)
+}
+
+func (b *Bot) state() State {
+	// In seat order, as strategies draw from rng for each opponent in turn.
+	ids := make([]int, 0, len(b.players))
+	for id := range b.players {
+		if id != b.id {
+			ids = append(ids, int(id))
+		}
+	}
+	sort.Ints(ids)
+	opponents := make([]string, len(ids))
+	for i, id := range ids {
+		opponents[i] = b.players[uint8(id)]
+	}
+	return State{
+		Board:     b.board,
+		Crossed:   b.crossed,
+		Diagonals: b.config.Diagonals,
+		History:   b.history,
+		Opponents: opponents,
+	}
+}
@@ -0,0 +1,87 @@
+package bot
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+	"github.com/jayakrishnan-jayu/bin-go/bingo"
+	"github.com/jayakrishnan-jayu/bin-go/utils"
+)
+
+var ErrIncompatibleServer = errors.New("server does not speak a supported protocol version")
+
+// Connect joins the room at url as a bot named name, and plays until the
+// connection is closed. header is sent with the handshake, see
+// bingo.AuthHeader. tlsConfig is used for wss:// urls, nil for the
+// defaults.
+func Connect(url, name string, header http.Header, tlsConfig *tls.Config, options Options) error {
+	dialer := *websocket.DefaultDialer
+	dialer.Subprotocols = bingo.Subprotocols()
+	dialer.TLSClientConfig = tlsConfig
+	conn, resp, err := dialer.Dial(url, header)
+	if err != nil {
+		return bingo.DialError(err, resp)
+	}
+	defer conn.Close()
+	if _, ok := bingo.ParseSubprotocol(conn.Subprotocol()); !ok {
+		return ErrIncompatibleServer
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	out := make(chan []byte, 16)
+	var seq uint64
+	b := New(name, options, func(t bingo.MessageType, payload interface{}) {
+		output, err := bingo.Encode(t, atomic.AddUint64(&seq, 1), payload)
+		if err != nil {
+			log.Printf("%s: %v\n", name, err)
+			return
+		}
+		select {
+		case out <- output:
+		case <-done:
+		}
+	})
+	go writePump(conn, out, done)
+
+	conn.SetReadLimit(utils.MaxServerMessageSize)
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseNoStatusReceived) {
+				return nil
+			}
+			if reason := bingo.CloseReason(err); reason != "" {
+				return fmt.Errorf("server closed the connection: %s", reason)
+			}
+			return err
+		}
+		for _, m := range bytes.Split(message, utils.Newline) {
+			_, payload, err := bingo.Decode(m)
+			if err != nil {
+				log.Printf("%s: %v\n", name, err)
+				continue
+			}
+			b.Handle(payload)
+		}
+	}
+}
+
+func writePump(conn *websocket.Conn, out <-chan []byte, done <-chan struct{}) {
+	for {
+		select {
+		case message := <-out:
+			if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
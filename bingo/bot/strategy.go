@@ -0,0 +1,213 @@
+package bot
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/jayakrishnan-jayu/bin-go/bingo/engine"
+)
+
+// Move is a number crossed during the game.
+type Move struct {
+	Player string
+	Number uint8
+	// Set when the server moved for a player who ran out of time.
+	Auto bool
+}
+
+// State is what a bot knows when it is its turn.
+type State struct {
+	Board     [][]uint8
+	Crossed   map[uint8]bool
+	Diagonals bool
+	// Every move of the game so far, in order.
+	History []Move
+	// Names of the other players.
+	Opponents []string
+}
+
+// open returns the numbers that are still free to cross.
+func (s State) open() []uint8 {
+	numbers := make([]uint8, 0, len(s.Board)*len(s.Board))
+	for n := 1; n <= len(s.Board)*len(s.Board); n++ {
+		if !s.Crossed[uint8(n)] {
+			numbers = append(numbers, uint8(n))
+		}
+	}
+	return numbers
+}
+
+// Strategy picks the number a bot crosses on its turn.
+type Strategy interface {
+	Choose(s State, rng *rand.Rand) uint8
+}
+
+// Strategies lists the strategies by the name they are selected with.
+var Strategies = map[string]Strategy{
+	"random":    Random{},
+	"greedy":    Greedy{},
+	"defensive": Defensive{Samples: 200},
+}
+
+// StrategyNames returns the names in Strategies in order.
+func StrategyNames() []string {
+	names := make([]string, 0, len(Strategies))
+	for name := range Strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseStrategy returns the strategy with the given name.
+func ParseStrategy(name string) (Strategy, error) {
+	strategy, ok := Strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("strategy must be one of %s", strings.Join(StrategyNames(), ", "))
+	}
+	return strategy, nil
+}
+
+// Random crosses any free number.
+type Random struct{}
+
+func (Random) Choose(s State, rng *rand.Rand) uint8 {
+	open := s.open()
+	if len(open) == 0 {
+		return 0
+	}
+	return open[rng.Intn(len(open))]
+}
+
+// Greedy crosses the number that completes the most lines on its own board,
+// or failing that brings its lines closest to completion.
+type Greedy struct{}
+
+func (Greedy) Choose(s State, rng *rand.Rand) uint8 {
+	own := newLayout(s.Board, s.Crossed, s.Diagonals)
+	var best []uint8
+	var bestGain gain
+	for _, n := range s.open() {
+		g := own.gain(n)
+		switch {
+		case len(best) == 0 || bestGain.less(g):
+			best = []uint8{n}
+			bestGain = g
+		case !g.less(bestGain):
+			best = append(best, n)
+		}
+	}
+	if len(best) == 0 {
+		return 0
+	}
+	return best[rng.Intn(len(best))]
+}
+
+// Defensive crosses the number that helps the other players least, breaking
+// ties by what it does for its own board.
+//
+// Players are only dealt their own board and never see the seed the others
+// are drawn from, so it guesses them: it draws Samples random boards for
+// each opponent and weighs each by how likely the opponent's moves so far
+// would have been on it, assuming they play much like Greedy.
+type Defensive struct {
+	Samples int
+}
+
+func (d Defensive) Choose(s State, rng *rand.Rand) uint8 {
+	open := s.open()
+	if len(open) == 0 {
+		return 0
+	}
+	help := make(map[uint8]float64, len(open))
+	for _, opponent := range s.Opponents {
+		for n, h := range d.expectedHelp(s, opponent, open, rng) {
+			help[n] += h
+		}
+	}
+
+	own := newLayout(s.Board, s.Crossed, s.Diagonals)
+	var best []uint8
+	bestHelp := math.Inf(1)
+	var bestGain gain
+	for _, n := range open {
+		h, g := help[n], own.gain(n)
+		switch {
+		case h < bestHelp-1e-9, h < bestHelp+1e-9 && bestGain.less(g):
+			best = []uint8{n}
+			bestHelp, bestGain = h, g
+		case h < bestHelp+1e-9 && !g.less(bestGain):
+			best = append(best, n)
+		}
+	}
+	return best[rng.Intn(len(best))]
+}
+
+// expectedHelp estimates how much crossing each open number would advance
+// the opponent's board.
+func (d Defensive) expectedHelp(s State, opponent string, open []uint8, rng *rand.Rand) map[uint8]float64 {
+	size := len(s.Board)
+	samples := d.Samples
+	if samples < 1 {
+		samples = 1
+	}
+	boards := make([]*layout, samples)
+	logWeights := make([]float64, samples)
+	for i := range boards {
+		boards[i], logWeights[i] = d.sample(s, opponent, rng)
+	}
+
+	maxLog := math.Inf(-1)
+	for _, w := range logWeights {
+		maxLog = math.Max(maxLog, w)
+	}
+	total := 0.0
+	weights := make([]float64, samples)
+	for i, w := range logWeights {
+		weights[i] = math.Exp(w - maxLog)
+		total += weights[i]
+	}
+
+	help := make(map[uint8]float64, len(open))
+	for i, board := range boards {
+		for _, n := range open {
+			help[n] += weights[i] / total * board.gain(n).value(size)
+		}
+	}
+	return help
+}
+
+// sample draws a board for the opponent, replays the game on it and returns
+// it with the log likelihood of the opponent's moves.
+func (d Defensive) sample(s State, opponent string, rng *rand.Rand) (*layout, float64) {
+	size := len(s.Board)
+	board := newLayout(engine.NewBoard(size, rng), nil, s.Diagonals)
+	crossed := make([]bool, size*size+1)
+	logWeight := 0.0
+	for _, move := range s.History {
+		if int(move.Number) >= len(crossed) {
+			continue
+		}
+		if move.Player == opponent && !move.Auto {
+			logWeight += moveLikelihood(board, crossed, move.Number)
+		}
+		crossed[move.Number] = true
+		board.cross(move.Number)
+	}
+	return board, logWeight
+}
+
+// moveLikelihood is the log probability that a player on board picks n, if
+// they pick each free number with a probability in proportion to its gain.
+func moveLikelihood(board *layout, crossed []bool, n uint8) float64 {
+	total := 0.0
+	for m := 1; m < len(crossed); m++ {
+		if !crossed[m] {
+			total += board.gain(uint8(m)).value(board.size)
+		}
+	}
+	return math.Log(board.gain(n).value(board.size) / total)
+}
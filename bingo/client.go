@@ -2,154 +2,189 @@ package bingo
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
 	"log"
 	"net"
-	"time"
 
-	"github.com/gorilla/websocket"
 	"github.com/jayakrishnan-jayu/bin-go/utils"
 )
 
 type Client struct {
-	Id         uint8           `json:"id"`
-	Name       string          `json:"name"`
-	Ip         net.IP          `json:"ip"`
-	Conn       *websocket.Conn `json:"-"`
-	game       *Game           `json:"-"`
-	Send       chan []byte     `json:"-"`
-	board      *[][]uint8      `json:"-"`
-	score      uint8           `json:"-"`
-	scoreIndex uint8           `json:"-"`
+	Id   uint8  `json:"id"`
+	Name string `json:"name"`
+	Ip   net.IP `json:"ip"`
+	// Set for AI players the host added to the room.
+	Bot   bool        `json:"bot"`
+	conn  PlayerConn  `json:"-"`
+	game  *Game       `json:"-"`
+	Send  chan []byte `json:"-"`
+	board Board       `json:"-"`
+	// Set when the name was given on connecting and cannot change.
+	nameLocked bool `json:"-"`
+	// Consecutive turns the client ran out of time on.
+	timeouts int `json:"-"`
+	// Protocol errors reported to the client, only touched by readPump.
+	errors int `json:"-"`
+	// False while the seat is held for a dropped connection.
+	Connected bool `json:"connected"`
+	// Secret the client presents to resume its seat.
+	token string `json:"-"`
+	// Closed once the client has left the game for good.
+	gone chan struct{} `json:"-"`
+	// Set when the client voted for a new round.
+	votedNewRound bool `json:"-"`
+	// Set for clients watching the game without playing.
+	spectator bool `json:"-"`
 }
 
 func (client *Client) String() string {
 	return fmt.Sprintf("Id: %d, Name: %s, Ip: %s", client.Id, client.Name, client.Ip)
 }
 
-func (c *Client) SetSocketReadConfig() {
-	c.Conn.SetReadLimit(utils.MaxMessageSize)
-	c.Conn.SetReadDeadline(time.Now().Add(utils.PongWait))
-	c.Conn.SetPongHandler(func(string) error {
-		c.Conn.SetReadDeadline(time.Now().Add(utils.PongWait))
-		return nil
-	})
-}
-
-func (c *Client) ReadMessages() ([][]byte, bool) {
-	_, message, err := c.Conn.ReadMessage()
-	if err != nil {
-		if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-			// log.Printf("error: %v", err)
-		} else {
-			// log.Printf("closed: %v", err)
+// writePump and readPump are bound to one connection, since a resumed
+// client gets a new conn and Send. Messages queued while a frame is being
+// sent go out together in the next one.
+func (c *Client) writePump(conn PlayerConn, send chan []byte) {
+	defer c.game.pumps.Done()
+	defer c.closeConn(conn)
+	for message := range send {
+		frame := [][]byte{message}
+		for n := len(send); n > 0; n-- {
+			frame = append(frame, <-send)
+		}
+		if err := conn.Send(bytes.Join(frame, utils.Newline)); err != nil {
+			return
 		}
-		return nil, false
-	}
-	messages := bytes.Split(message, utils.Newline)
-	return messages, true
-}
-
-func (c *Client) HandleSocketPing() error {
-	c.Conn.SetWriteDeadline(time.Now().Add(utils.WriteWait))
-	if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-		return err
 	}
-	return nil
 }
 
-func (c *Client) writePump() {
-	ticker := time.NewTicker(utils.PingPeriod)
-	defer func() {
-		ticker.Stop()
-		c.Conn.Close()
-	}()
+func (c *Client) readPump(conn PlayerConn) {
+	defer c.disconnected(conn)
 	for {
-		select {
-		case message, ok := <-c.Send:
-			c.Conn.SetWriteDeadline(time.Now().Add(utils.WriteWait))
-			if !ok {
-				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
-			w, err := c.Conn.NextWriter(websocket.TextMessage)
+		frame, err := conn.Receive()
+		if err != nil {
+			return
+		}
+		for _, message := range bytes.Split(frame, utils.Newline) {
+			_, payload, err := Decode(message)
 			if err != nil {
-				return
-			}
-			w.Write(message)
-			n := len(c.Send)
-			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-c.Send)
+				err = decodeError(err)
+			} else {
+				err = c.handlePlayerResponse(payload)
 			}
-			if err := w.Close(); err != nil {
+			if err != nil && !c.reject(err) {
 				return
 			}
-		case <-ticker.C:
-			if err := c.HandleSocketPing(); err != nil {
-				return
-			}
-
 		}
 	}
 }
 
-func (c *Client) readPump() {
-	defer func() {
-		c.game.unregister <- c
-		c.Conn.Close()
-	}()
-	c.SetSocketReadConfig()
-	for {
-		var messageMap map[string]interface{}
-		messages, ok := c.ReadMessages()
-		if !ok {
-			break
-		}
-		for _, message := range messages {
-			err := json.Unmarshal(message, &messageMap)
-			if err != nil {
-				log.Fatalf("json: %v", err)
-				break
-			}
-			cmd, ok := utils.GetCommandFromMap(messageMap)
-			if !ok {
-				break
-			}
-			c.handlePlayerResponse(cmd, message)
-		}
+// disconnected tells the game that conn has gone away.
+func (c *Client) disconnected(conn PlayerConn) {
+	select {
+	case c.game.disconnect <- connection{client: c, conn: conn}:
+	case <-c.game.quit:
 	}
 }
 
-func (c *Client) handlePlayerResponse(cmd int, message []byte) {
-	switch cmd {
-	case PlayerNameCommand:
-		var playerUserName PlayerName
-		err := json.Unmarshal(message, &playerUserName)
-		if err != nil {
-			log.Println(err)
-			break
+// reject reports err back to the client. It returns false if the client
+// should be disconnected, either because err is not a client mistake or
+// because the client has made MaxErrors of them.
+func (c *Client) reject(err error) bool {
+	perr, ok := err.(*ProtocolError)
+	if !ok {
+		log.Println("readPump: ", err)
+		return false
+	}
+	c.sendError(perr)
+	c.errors++
+	if c.game.MaxErrors > 0 && c.errors >= c.game.MaxErrors {
+		c.sendError(newProtocolError(ErrorCodeTooManyErrors, "disconnected after %d errors", c.errors))
+		log.Printf("%s disconnected after %d errors\n", c.Name, c.errors)
+		return false
+	}
+	return true
+}
+
+func (c *Client) handlePlayerResponse(payload interface{}) error {
+	if _, ok := payload.(*Leaderboard); ok {
+		c.sendLeaderboard()
+		return nil
+	}
+	if c.spectator {
+		return nil
+	}
+	switch msg := payload.(type) {
+	case *PlayerName:
+		if err := c.setName(msg.Name); err != nil {
+			return err
 		}
-		c.Name = playerUserName.Name
 		c.game.broadcastPlayerlist()
-	case PlayerBoardCommand:
-		var playerBoard PlayersBoard
-		err := json.Unmarshal(message, &playerBoard)
-		if err != nil {
-			log.Println(err)
-			break
+	case *PlayersBoard:
+		if err := c.setBoard(msg.Board); err != nil {
+			return err
 		}
-		c.board = playerBoard.Board
-	case GameMoveCommand:
-		var gameMove GameMove
-		err := json.Unmarshal(message, &gameMove)
-		if err != nil {
-			log.Println(err)
-			break
+	case *RoundVote:
+		return c.game.voteNewRound(c)
+	case *GameMove:
+		return c.submitMove(*msg)
+	}
+	return nil
+}
+
+// submitMove hands a move to the play loop if it is c's turn. Moves made
+// for an earlier turn are dropped, as they crossed with the turn ending, and
+// so are moves the round ended before taking.
+func (c *Client) submitMove(move GameMove) error {
+	g := c.game
+	g.lock.RLock()
+	turnPlayer, turn, round := g.turnPlayer, g.turn, g.round
+	g.lock.RUnlock()
+	if turnPlayer == 0 {
+		return newProtocolError(ErrorCodeNotYourTurn, "the game has not started")
+	}
+	if move.Turn != 0 && move.Turn < turn {
+		return nil
+	}
+	if turnPlayer != c.Id {
+		return newProtocolError(ErrorCodeNotYourTurn, "it is not your turn")
+	}
+	if move.Turn != turn {
+		return newProtocolError(ErrorCodeIllegalMove, "move is for turn %d, not the current turn %d", move.Turn, turn)
+	}
+	move.Author = c
+	select {
+	case g.receive <- move:
+	case <-round:
+	case <-c.gone:
+	case <-g.quit:
+	}
+	return nil
+}
+
+// setBoard checks a board the player sent against the one they were dealt.
+// Players cannot choose their own board, so anything else is refused.
+func (c *Client) setBoard(board Board) error {
+	g := c.game
+	g.lock.RLock()
+	defer g.lock.RUnlock()
+	if !g.IsLobbyMode {
+		return newProtocolError(ErrorCodeLobbyClosed, "boards can only be submitted before the game starts")
+	}
+	if board == nil || !sameBoard(board, c.board) {
+		return newProtocolError(ErrorCodeInvalidBoard, "board is not the one you were dealt")
+	}
+	return nil
+}
+
+func sameBoard(a, b [][]uint8) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
 		}
-		gameMove.Author = c
-		c.game.receive <- gameMove
-		c.game.broadcastGameMove(&gameMove)
 	}
+	return true
 }
@@ -0,0 +1,161 @@
+package bingo
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/jayakrishnan-jayu/bin-go/utils"
+)
+
+var ErrConnClosed = errors.New("connection closed")
+
+// PlayerConn carries frames of encoded messages between the game and one
+// player. A frame holds one or more messages separated by newlines.
+type PlayerConn interface {
+	Send(frame []byte) error
+	// Receive blocks until the player sends a frame, and returns an error
+	// once the connection is closed.
+	Receive() ([]byte, error)
+	Close() error
+}
+
+// wsConn is a player on the other end of a websocket.
+type wsConn struct {
+	conn      *websocket.Conn
+	done      chan struct{}
+	closeOnce sync.Once
+	// Frees the connection's place in the admission limits, if it has one.
+	release func()
+}
+
+// NewWebSocketConn wraps a websocket a player connected with, and keeps it
+// alive with pings until it is closed.
+func NewWebSocketConn(conn *websocket.Conn) PlayerConn {
+	c := &wsConn{conn: conn, done: make(chan struct{})}
+	conn.SetReadLimit(utils.MaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(utils.PongWait))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(utils.PongWait))
+		return nil
+	})
+	go c.ping()
+	return c
+}
+
+func (c *wsConn) ping() {
+	ticker := time.NewTicker(utils.PingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(utils.WriteWait)); err != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *wsConn) Send(frame []byte) error {
+	c.conn.SetWriteDeadline(time.Now().Add(utils.WriteWait))
+	return c.conn.WriteMessage(websocket.TextMessage, frame)
+}
+
+func (c *wsConn) Receive() ([]byte, error) {
+	_, frame, err := c.conn.ReadMessage()
+	return frame, err
+}
+
+// Close tells the player the game is done with them and closes the socket.
+func (c *wsConn) Close() error {
+	return c.close([]byte{})
+}
+
+// close sends a close frame with payload and closes the socket.
+func (c *wsConn) close(payload []byte) error {
+	err := ErrConnClosed
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.WriteControl(websocket.CloseMessage, payload, time.Now().Add(utils.WriteWait))
+		err = c.conn.Close()
+		if c.release != nil {
+			c.release()
+		}
+	})
+	return err
+}
+
+// Close frames can carry at most this many bytes of reason.
+const maxCloseReason = 123
+
+// closeWith closes conn with a close code and the reason for it, for
+// clients to show. Connections without close codes are simply closed.
+func closeWith(conn PlayerConn, code int, reason string) error {
+	c, ok := conn.(*wsConn)
+	if !ok {
+		return conn.Close()
+	}
+	if len(reason) > maxCloseReason {
+		reason = reason[:maxCloseReason]
+	}
+	return c.close(websocket.FormatCloseMessage(code, reason))
+}
+
+// pipeConn is one end of a Pipe.
+type pipeConn struct {
+	in   <-chan []byte
+	out  chan<- []byte
+	done chan struct{}
+	once *sync.Once
+}
+
+// Pipe returns the two ends of an in-memory connection, so players can run
+// in the same process as the game. Frames sent on one end are received on
+// the other, and closing either end closes both.
+func Pipe() (PlayerConn, PlayerConn) {
+	ab := make(chan []byte, 256)
+	ba := make(chan []byte, 256)
+	done := make(chan struct{})
+	once := &sync.Once{}
+	return &pipeConn{in: ba, out: ab, done: done, once: once},
+		&pipeConn{in: ab, out: ba, done: done, once: once}
+}
+
+func (p *pipeConn) Send(frame []byte) error {
+	select {
+	case <-p.done:
+		return ErrConnClosed
+	default:
+	}
+	select {
+	case p.out <- frame:
+		return nil
+	case <-p.done:
+		return ErrConnClosed
+	}
+}
+
+// Receive returns the frames sent before the pipe was closed before
+// reporting io.EOF.
+func (p *pipeConn) Receive() ([]byte, error) {
+	select {
+	case frame := <-p.in:
+		return frame, nil
+	case <-p.done:
+		select {
+		case frame := <-p.in:
+			return frame, nil
+		default:
+			return nil, io.EOF
+		}
+	}
+}
+
+func (p *pipeConn) Close() error {
+	p.once.Do(func() { close(p.done) })
+	return nil
+}
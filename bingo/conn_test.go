@@ -0,0 +1,91 @@
+package bingo
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestPipe(t *testing.T) {
+	a, b := Pipe()
+	defer a.Close()
+	for _, tt := range []struct {
+		name     string
+		from, to PlayerConn
+	}{
+		{"a to b", a, b},
+		{"b to a", b, a},
+	} {
+		frames := []string{"one", "two", "three"}
+		for _, f := range frames {
+			if err := tt.from.Send([]byte(f)); err != nil {
+				t.Fatalf("%s: Send: %v", tt.name, err)
+			}
+		}
+		for _, want := range frames {
+			got, err := tt.to.Receive()
+			if err != nil {
+				t.Fatalf("%s: Receive: %v", tt.name, err)
+			}
+			if string(got) != want {
+				t.Errorf("%s: got %q, want %q", tt.name, got, want)
+			}
+		}
+	}
+}
+
+func TestPipeClose(t *testing.T) {
+	for _, closer := range []string{"sender", "receiver"} {
+		a, b := Pipe()
+		if err := a.Send([]byte("sent before closing")); err != nil {
+			t.Fatal(err)
+		}
+		if closer == "sender" {
+			a.Close()
+		} else {
+			b.Close()
+		}
+		// Closing twice is harmless.
+		a.Close()
+
+		if err := a.Send([]byte("late")); !errors.Is(err, ErrConnClosed) {
+			t.Errorf("%s closed: Send on a got %v, want ErrConnClosed", closer, err)
+		}
+		if err := b.Send([]byte("late")); !errors.Is(err, ErrConnClosed) {
+			t.Errorf("%s closed: Send on b got %v, want ErrConnClosed", closer, err)
+		}
+		if frame, err := b.Receive(); err != nil || string(frame) != "sent before closing" {
+			t.Errorf("%s closed: got %q, %v, want the frame sent before closing", closer, frame, err)
+		}
+		if _, err := b.Receive(); err != io.EOF {
+			t.Errorf("%s closed: got %v, want io.EOF", closer, err)
+		}
+		if _, err := a.Receive(); err != io.EOF {
+			t.Errorf("%s closed: got %v on the other end, want io.EOF", closer, err)
+		}
+	}
+}
+
+func TestPipeCloseUnblocksReceive(t *testing.T) {
+	a, b := Pipe()
+	received := make(chan error, 1)
+	go func() {
+		_, err := b.Receive()
+		received <- err
+	}()
+	select {
+	case err := <-received:
+		t.Fatalf("Receive returned %v before anything was sent", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	a.Close()
+	select {
+	case err := <-received:
+		if err != io.EOF {
+			t.Errorf("got %v, want io.EOF", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Receive still blocked after the pipe was closed")
+	}
+}
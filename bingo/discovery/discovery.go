@@ -0,0 +1,177 @@
+// Package discovery lets bingo servers announce themselves on the LAN, so
+// players can find them without typing in an address.
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/jayakrishnan-jayu/bin-go/bingo"
+)
+
+const (
+	// Port beacons are broadcast to.
+	Port = 8081
+	// How often a server broadcasts its beacon.
+	Interval = 2 * time.Second
+	// Service names bingo beacons, so other broadcasts on Port are ignored.
+	Service = "bin-go"
+	// Beacons are cut down to fit in one datagram on any LAN.
+	MaxBeaconSize = 1400
+)
+
+// Beacon is what a server broadcasts about itself.
+type Beacon struct {
+	Service string `json:"service"`
+	// Tells apart servers that are heard on more than one address.
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Port the game is served on.
+	Port     int  `json:"port"`
+	TLS      bool `json:"tls"`
+	Protocol int  `json:"protocol"`
+	// The rooms open on the server, as many as fit in the beacon.
+	Rooms []bingo.RoomInfo `json:"rooms"`
+}
+
+// Server is a server that was heard on the LAN.
+type Server struct {
+	Beacon
+	// Address the beacon came from.
+	IP net.IP
+}
+
+// Addr returns the address to connect to the server on.
+func (s Server) Addr() string {
+	return net.JoinHostPort(s.IP.String(), fmt.Sprint(s.Port))
+}
+
+// encode marshals b, leaving out the last rooms if it does not fit in a
+// datagram.
+func (b Beacon) encode() ([]byte, error) {
+	b.Service = Service
+	for {
+		data, err := json.Marshal(b)
+		if err != nil || len(data) <= MaxBeaconSize || len(b.Rooms) == 0 {
+			return data, err
+		}
+		b.Rooms = b.Rooms[:len(b.Rooms)-1]
+	}
+}
+
+// broadcastAddrs returns where to send beacons: the broadcast address of
+// every IPv4 network this machine is on, and loopback for players on the
+// same machine.
+func broadcastAddrs() []*net.UDPAddr {
+	addrs := []*net.UDPAddr{
+		{IP: net.IPv4bcast, Port: Port},
+		{IP: net.IPv4(127, 0, 0, 1), Port: Port},
+	}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return addrs
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagBroadcast == 0 {
+			continue
+		}
+		ifaceAddrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range ifaceAddrs {
+			ipnet, ok := a.(*net.IPNet)
+			if !ok || ipnet.IP.To4() == nil || len(ipnet.Mask) != net.IPv4len {
+				continue
+			}
+			ip := ipnet.IP.To4()
+			bcast := make(net.IP, net.IPv4len)
+			for i := range bcast {
+				bcast[i] = ip[i] | ^ipnet.Mask[i]
+			}
+			addrs = append(addrs, &net.UDPAddr{IP: bcast, Port: Port})
+		}
+	}
+	return addrs
+}
+
+// Announce broadcasts the beacon returned by beacon every Interval until
+// quit is closed. A nil quit announces for as long as the process runs.
+func Announce(beacon func() Beacon, quit <-chan struct{}) error {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	ticker := time.NewTicker(Interval)
+	defer ticker.Stop()
+	for {
+		data, err := beacon().encode()
+		if err != nil {
+			return err
+		}
+		// Interfaces can come and go, so they are looked up every time.
+		for _, addr := range broadcastAddrs() {
+			// Networks that cannot be reached are skipped.
+			conn.WriteToUDP(data, addr)
+		}
+		select {
+		case <-ticker.C:
+		case <-quit:
+			return nil
+		}
+	}
+}
+
+// Discover listens for beacons for wait and returns the servers heard,
+// sorted by name.
+func Discover(wait time.Duration) ([]Server, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{Port: Port})
+	if err != nil {
+		return nil, fmt.Errorf("listening for servers on port %d: %w", Port, err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(wait))
+
+	servers := make(map[string]*Server)
+	buf := make([]byte, 64*1024)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if e, ok := err.(net.Error); ok && e.Timeout() {
+				break
+			}
+			return nil, err
+		}
+		var b Beacon
+		if err := json.Unmarshal(buf[:n], &b); err != nil || b.Service != Service || b.ID == "" {
+			continue
+		}
+		s, ok := servers[b.ID]
+		if !ok {
+			servers[b.ID] = &Server{Beacon: b, IP: from.IP}
+			continue
+		}
+		// The LAN address is shown rather than loopback, so it can be
+		// passed on to other players.
+		s.Beacon = b
+		if s.IP.IsLoopback() && !from.IP.IsLoopback() {
+			s.IP = from.IP
+		}
+	}
+
+	list := make([]Server, 0, len(servers))
+	for _, s := range servers {
+		list = append(list, *s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].Addr() < list[j].Addr()
+	})
+	return list, nil
+}
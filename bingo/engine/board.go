@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// NewBoard returns a size x size board holding 1 to size*size in random
+// order.
+func NewBoard(size int, rng *rand.Rand) [][]uint8 {
+	numbers := rng.Perm(size * size)
+	board := make([][]uint8, size)
+	for i := range board {
+		board[i] = make([]uint8, size)
+		for j := range board[i] {
+			board[i][j] = uint8(numbers[i*size+j] + 1)
+		}
+	}
+	return board
+}
+
+// ValidateBoard checks that board is a size x size grid holding every number
+// from 1 to size*size exactly once.
+func ValidateBoard(board [][]uint8, size int) error {
+	if len(board) != size {
+		return fmt.Errorf("board must have %d rows", size)
+	}
+	seen := make([]bool, size*size+1)
+	for _, row := range board {
+		if len(row) != size {
+			return fmt.Errorf("board rows must have %d numbers", size)
+		}
+		for _, n := range row {
+			if n < 1 || int(n) > size*size {
+				return fmt.Errorf("board number %d is not between 1 and %d", n, size*size)
+			}
+			if seen[n] {
+				return fmt.Errorf("board number %d is repeated", n)
+			}
+			seen[n] = true
+		}
+	}
+	return nil
+}
+
+// Lines counts the rows, columns and, if diagonals is set, diagonals of
+// board whose numbers are all crossed. crossed is indexed by number.
+func Lines(board [][]uint8, crossed []bool, diagonals bool) uint8 {
+	n := len(board)
+	var lines uint8
+	diag, inverseDiag := true, true
+	for i := 0; i < n; i++ {
+		row, col := true, true
+		for j := 0; j < n; j++ {
+			row = row && crossed[board[i][j]]
+			col = col && crossed[board[j][i]]
+		}
+		if row {
+			lines++
+		}
+		if col {
+			lines++
+		}
+		diag = diag && crossed[board[i][i]]
+		inverseDiag = inverseDiag && crossed[board[i][n-1-i]]
+	}
+	if diagonals {
+		if diag {
+			lines++
+		}
+		if inverseDiag {
+			lines++
+		}
+	}
+	return lines
+}
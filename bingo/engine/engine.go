@@ -0,0 +1,300 @@
+// Package engine holds the rules of bingo. It has no connections, clocks or
+// output of its own: a State only changes by applying moves to it, so the
+// server, bots and replays all play by the same rules.
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	ErrGameOver   = errors.New("the game is over")
+	ErrNotPlaying = errors.New("player is not playing")
+)
+
+// IllegalMoveError is returned for a number that cannot be crossed.
+type IllegalMoveError struct {
+	Number uint8
+	Reason string
+}
+
+func (e *IllegalMoveError) Error() string {
+	return fmt.Sprintf("%d %s", e.Number, e.Reason)
+}
+
+// Rules configures a game.
+type Rules struct {
+	// Number of rows and columns on each board.
+	Size uint8
+	// Completed lines needed to finish.
+	WinLines uint8
+	// Whether the two diagonals count as lines.
+	Diagonals bool
+	// Players that have to finish before the game is over, 0 to play until
+	// every player has finished.
+	Finishers int
+}
+
+type Player struct {
+	ID    uint8
+	Name  string
+	Board [][]uint8
+	// Completed lines on the board.
+	Lines uint8
+	// Order the player finished in, 0 while still playing. Players that
+	// finish on the same move share a place.
+	Place uint8
+	// Numbers the player crossed.
+	Moves int
+	// Set once the player has left the game, after LeftAfter moves.
+	Left      bool
+	LeftAfter int
+}
+
+func (p Player) Finished() bool {
+	return p.Place > 0
+}
+
+// Playing reports whether the player still takes turns.
+func (p Player) Playing() bool {
+	return !p.Left && !p.Finished()
+}
+
+// Move crosses Number on every board.
+type Move struct {
+	Player uint8
+	Number uint8
+	// Set when the move was made for a player who ran out of time.
+	Auto bool
+}
+
+// Event describes something a move caused.
+type Event interface {
+	event()
+}
+
+// Crossed is the move itself.
+type Crossed struct {
+	Move
+}
+
+// LinesCompleted is sent when a move completes lines on a player's board.
+type LinesCompleted struct {
+	Player uint8
+	Lines  uint8
+}
+
+// Finished is sent when a player reaches WinLines.
+type Finished struct {
+	Player uint8
+	Place  uint8
+}
+
+// GameOver is sent after the move that ends the game.
+type GameOver struct{}
+
+func (Crossed) event()        {}
+func (LinesCompleted) event() {}
+func (Finished) event()       {}
+func (GameOver) event()       {}
+
+// State is a game at one point in time. Its methods never change it; Apply
+// returns a new State instead, so older ones can be kept around.
+type State struct {
+	Rules   Rules
+	Players []Player
+	// Indexed by number, 0 is not on any board.
+	Crossed []bool
+	Moves   []Move
+
+	// Place given to the next players to finish.
+	place uint8
+}
+
+// New starts a game between players, in the order they take turns.
+func New(rules Rules, players []Player) State {
+	s := State{
+		Rules:   rules,
+		Players: make([]Player, len(players)),
+		Crossed: make([]bool, int(rules.Size)*int(rules.Size)+1),
+		place:   1,
+	}
+	for i, p := range players {
+		s.Players[i] = Player{ID: p.ID, Name: p.Name, Board: p.Board}
+	}
+	return s
+}
+
+func (s State) clone() State {
+	next := s
+	next.Players = append([]Player(nil), s.Players...)
+	next.Crossed = append([]bool(nil), s.Crossed...)
+	// Appending to Moves must not write into an older State's array.
+	next.Moves = s.Moves[:len(s.Moves):len(s.Moves)]
+	return next
+}
+
+// Player returns the player with the given id.
+func (s State) Player(id uint8) (Player, bool) {
+	for _, p := range s.Players {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return Player{}, false
+}
+
+// Check reports whether n can be crossed.
+func (s State) Check(n uint8) error {
+	max := int(s.Rules.Size) * int(s.Rules.Size)
+	if n < 1 || int(n) > max {
+		return &IllegalMoveError{n, fmt.Sprintf("is not between 1 and %d", max)}
+	}
+	if s.Crossed[n] {
+		return &IllegalMoveError{n, "is already crossed"}
+	}
+	return nil
+}
+
+// Open returns the numbers that have not been crossed yet.
+func (s State) Open() []uint8 {
+	open := make([]uint8, 0, len(s.Crossed))
+	for n := 1; n < len(s.Crossed); n++ {
+		if !s.Crossed[n] {
+			open = append(open, uint8(n))
+		}
+	}
+	return open
+}
+
+// Over reports whether enough players have finished, or nobody is left to
+// play.
+func (s State) Over() bool {
+	finished, playing := 0, 0
+	for _, p := range s.Players {
+		switch {
+		case p.Left:
+		case p.Finished():
+			finished++
+		default:
+			playing++
+		}
+	}
+	if playing == 0 {
+		return true
+	}
+	return s.Rules.Finishers > 0 && finished >= s.Rules.Finishers
+}
+
+// Apply crosses the move's number for everyone and scores every board. Who
+// moves when is up to the caller; Apply only checks that the player is still
+// playing.
+func (s State) Apply(m Move) (State, []Event, error) {
+	if s.Over() {
+		return s, nil, ErrGameOver
+	}
+	author := -1
+	for i, p := range s.Players {
+		if p.ID == m.Player && p.Playing() {
+			author = i
+		}
+	}
+	if author < 0 {
+		return s, nil, ErrNotPlaying
+	}
+	if err := s.Check(m.Number); err != nil {
+		return s, nil, err
+	}
+
+	next := s.clone()
+	next.Crossed[m.Number] = true
+	next.Moves = append(next.Moves, m)
+	next.Players[author].Moves++
+	events := []Event{Crossed{m}}
+	finished := false
+	for i := range next.Players {
+		p := &next.Players[i]
+		if !p.Playing() {
+			continue
+		}
+		lines := Lines(p.Board, next.Crossed, s.Rules.Diagonals)
+		if lines == p.Lines {
+			continue
+		}
+		p.Lines = lines
+		events = append(events, LinesCompleted{Player: p.ID, Lines: lines})
+		if lines >= s.Rules.WinLines {
+			p.Place = next.place
+			finished = true
+			events = append(events, Finished{Player: p.ID, Place: p.Place})
+		}
+	}
+	if finished {
+		next.place++
+	}
+	if next.Over() {
+		events = append(events, GameOver{})
+	}
+	return next, events, nil
+}
+
+// Leave takes a player out of the game. Their crossed numbers stay crossed.
+func (s State) Leave(id uint8) State {
+	next := s.clone()
+	for i := range next.Players {
+		if p := &next.Players[i]; p.ID == id && !p.Left {
+			p.Left = true
+			p.LeftAfter = len(next.Moves)
+		}
+	}
+	return next
+}
+
+// Standing is a player's position at the end of a game.
+type Standing struct {
+	Rank int
+	Player
+}
+
+// Standings ranks finished players by the order they finished in, then
+// everyone else by completed lines. Players level on both share a rank.
+// Players that left are not ranked.
+func (s State) Standings() []Standing {
+	players := make([]Player, 0, len(s.Players))
+	for _, p := range s.Players {
+		if !p.Left {
+			players = append(players, p)
+		}
+	}
+	// Unfinished players sort after every finisher.
+	order := func(p Player) int {
+		if p.Finished() {
+			return int(p.Place)
+		}
+		return 1 << 16
+	}
+	sort.Slice(players, func(i, j int) bool {
+		a, b := players[i], players[j]
+		if order(a) != order(b) {
+			return order(a) < order(b)
+		}
+		if a.Lines != b.Lines {
+			return a.Lines > b.Lines
+		}
+		return a.ID < b.ID
+	})
+	standings := make([]Standing, len(players))
+	for i, p := range players {
+		rank := i + 1
+		if i > 0 {
+			prev := players[i-1]
+			if order(prev) == order(p) && prev.Lines == p.Lines {
+				rank = standings[i-1].Rank
+			}
+		}
+		standings[i] = Standing{Rank: rank, Player: p}
+	}
+	return standings
+}
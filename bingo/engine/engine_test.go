@@ -0,0 +1,290 @@
+package engine
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Boards of the test players, laid out so the same numbers complete
+// different lines on each.
+var (
+	rows = [][]uint8{
+		{1, 2, 3},
+		{4, 5, 6},
+		{7, 8, 9},
+	}
+	scattered = [][]uint8{
+		{2, 9, 4},
+		{7, 1, 6},
+		{5, 8, 3},
+	}
+	spiral = [][]uint8{
+		{1, 2, 3},
+		{8, 9, 4},
+		{7, 6, 5},
+	}
+)
+
+func newGame(rules Rules) State {
+	return New(rules, []Player{
+		{ID: 1, Name: "rows", Board: rows},
+		{ID: 2, Name: "scattered", Board: scattered},
+		{ID: 3, Name: "spiral", Board: spiral},
+	})
+}
+
+// play applies moves, taking turns in player order, and fails the test on
+// the first error.
+func play(t *testing.T, s State, numbers ...uint8) State {
+	t.Helper()
+	for i, n := range numbers {
+		next, _, err := s.Apply(Move{Player: s.Players[i%len(s.Players)].ID, Number: n})
+		if err != nil {
+			t.Fatalf("move %d (%d): %v", i+1, n, err)
+		}
+		s = next
+	}
+	return s
+}
+
+func TestApplyIllegal(t *testing.T) {
+	rules := Rules{Size: 3, WinLines: 1, Finishers: 1}
+	tests := []struct {
+		name    string
+		state   State
+		move    Move
+		illegal bool
+		err     error
+	}{
+		{"zero", newGame(rules), Move{Player: 1, Number: 0}, true, nil},
+		{"too big", newGame(rules), Move{Player: 1, Number: 10}, true, nil},
+		{"duplicate", play(t, newGame(rules), 4), Move{Player: 2, Number: 4}, true, nil},
+		{"unknown player", newGame(rules), Move{Player: 9, Number: 1}, false, ErrNotPlaying},
+		{"left", newGame(rules).Leave(1), Move{Player: 1, Number: 1}, false, ErrNotPlaying},
+		{"game over", play(t, newGame(rules), 1, 4, 2, 5, 3), Move{Player: 3, Number: 6}, false, ErrGameOver},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, events, err := tt.state.Apply(tt.move)
+			var illegal *IllegalMoveError
+			if tt.illegal && !errors.As(err, &illegal) {
+				t.Fatalf("got %v, want an IllegalMoveError", err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+			if events != nil {
+				t.Errorf("got events %v for an illegal move", events)
+			}
+			if !reflect.DeepEqual(next, tt.state) {
+				t.Error("an illegal move changed the state")
+			}
+		})
+	}
+}
+
+func TestApplyKeepsOlderStates(t *testing.T) {
+	s := play(t, newGame(Rules{Size: 3, WinLines: 3}), 1, 2)
+	a, _, _ := s.Apply(Move{Player: 3, Number: 3})
+	b, _, _ := s.Apply(Move{Player: 3, Number: 4})
+	if len(s.Moves) != 2 || s.Crossed[3] || s.Crossed[4] || s.Players[2].Moves != 0 {
+		t.Fatal("Apply changed the state it was called on")
+	}
+	if a.Moves[2].Number != 3 || b.Moves[2].Number != 4 {
+		t.Fatalf("states share their moves: %v, %v", a.Moves, b.Moves)
+	}
+}
+
+func TestApplyWin(t *testing.T) {
+	tests := []struct {
+		name      string
+		diagonals bool
+		moves     []uint8
+		// Lines of each player after the moves, and who finished.
+		lines    []uint8
+		finished []uint8
+	}{
+		{"row", false, []uint8{1, 2, 3}, []uint8{1, 0, 1}, []uint8{1, 3}},
+		{"column", false, []uint8{1, 4, 7}, []uint8{1, 0, 0}, []uint8{1}},
+		{"diagonal", true, []uint8{1, 5, 9}, []uint8{1, 0, 1}, []uint8{1, 3}},
+		{"diagonal not counted", false, []uint8{1, 5, 9}, []uint8{0, 0, 0}, nil},
+		{"inverse diagonal", true, []uint8{4, 1, 5}, []uint8{0, 1, 0}, []uint8{2}},
+		{"inverse diagonal not counted", false, []uint8{4, 1, 5}, []uint8{0, 0, 0}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newGame(Rules{Size: 3, WinLines: 1, Diagonals: tt.diagonals})
+			var finished []uint8
+			for i, n := range tt.moves {
+				next, events, err := s.Apply(Move{Player: s.Players[i%3].ID, Number: n})
+				if errors.Is(err, ErrNotPlaying) {
+					// The player has already finished.
+					next, events, err = s.Apply(Move{Player: s.Players[(i+1)%3].ID, Number: n})
+				}
+				if err != nil {
+					t.Fatalf("move %d: %v", n, err)
+				}
+				for _, e := range events {
+					if f, ok := e.(Finished); ok {
+						finished = append(finished, f.Player)
+					}
+				}
+				s = next
+			}
+			for i, p := range s.Players {
+				if p.Lines != tt.lines[i] {
+					t.Errorf("%s has %d lines, want %d", p.Name, p.Lines, tt.lines[i])
+				}
+			}
+			if !reflect.DeepEqual(finished, tt.finished) {
+				t.Errorf("finished %v, want %v", finished, tt.finished)
+			}
+		})
+	}
+}
+
+func TestApplyEvents(t *testing.T) {
+	s := play(t, newGame(Rules{Size: 3, WinLines: 1, Finishers: 1}), 1, 4)
+	_, events, err := s.Apply(Move{Player: 3, Number: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Event{
+		Crossed{Move{Player: 3, Number: 7}},
+		LinesCompleted{Player: 1, Lines: 1},
+		Finished{Player: 1, Place: 1},
+		GameOver{},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("got %#v, want %#v", events, want)
+	}
+}
+
+func TestStandings(t *testing.T) {
+	type rank struct {
+		id    uint8
+		rank  int
+		place uint8
+	}
+	tests := []struct {
+		name      string
+		finishers int
+		moves     []uint8
+		over      bool
+		want      []rank
+	}{
+		// Rows finishes on 7, which ends a game for the top one. The others
+		// have no lines and share the next rank.
+		{"top one", 1, []uint8{1, 4, 7}, true, []rank{{1, 1, 1}, {2, 2, 0}, {3, 2, 0}}},
+		// Playing on, spiral finishes on 3 and scattered on 5.
+		{"all", 0, []uint8{1, 4, 7, 2, 3}, false, []rank{{1, 1, 1}, {3, 2, 2}, {2, 3, 0}}},
+		{"all over", 0, []uint8{1, 4, 7, 2, 3, 5}, true, []rank{{1, 1, 1}, {3, 2, 2}, {2, 3, 3}}},
+		// Rows and spiral finish on the same move.
+		{"shared place", 0, []uint8{1, 2, 3}, false, []rank{{1, 1, 1}, {3, 1, 1}, {2, 3, 0}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newGame(Rules{Size: 3, WinLines: 1, Finishers: tt.finishers})
+			for _, n := range tt.moves {
+				// Whoever is still playing crosses the number.
+				var err error
+				for _, p := range s.Players {
+					if p.Playing() {
+						s, _, err = s.Apply(Move{Player: p.ID, Number: n})
+						break
+					}
+				}
+				if err != nil {
+					t.Fatalf("move %d: %v", n, err)
+				}
+			}
+			if s.Over() != tt.over {
+				t.Errorf("Over() = %v, want %v", s.Over(), tt.over)
+			}
+			var got []rank
+			for _, st := range s.Standings() {
+				got = append(got, rank{st.ID, st.Rank, st.Place})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStandingsSkipLeft(t *testing.T) {
+	s := play(t, newGame(Rules{Size: 3, WinLines: 1}), 1, 2).Leave(1)
+	for _, st := range s.Standings() {
+		if st.ID == 1 {
+			t.Fatalf("a player who left is ranked: %v", st)
+		}
+	}
+	if p, _ := s.Leave(1).Player(1); p.LeftAfter != 2 {
+		t.Errorf("leaving again moved LeftAfter to %d, want 2", p.LeftAfter)
+	}
+	if s = s.Leave(2).Leave(3); !s.Over() {
+		t.Error("a game nobody is left in is not over")
+	}
+}
+
+func TestNewBoard(t *testing.T) {
+	for _, size := range []int{1, 3, 5, 9} {
+		a := NewBoard(size, NewRand(42, 1))
+		if err := ValidateBoard(a, size); err != nil {
+			t.Errorf("size %d: %v", size, err)
+		}
+		if b := NewBoard(size, NewRand(42, 1)); !reflect.DeepEqual(a, b) {
+			t.Errorf("size %d: the same seed dealt %v and %v", size, a, b)
+		}
+	}
+	if reflect.DeepEqual(NewBoard(5, NewRand(42, 1)), NewBoard(5, NewRand(42, 2))) {
+		t.Error("two players were dealt the same board")
+	}
+	if reflect.DeepEqual(NewBoard(5, NewRand(42, 1)), NewBoard(5, NewRand(NextSeed(42), 1))) {
+		t.Error("the next round dealt the same board")
+	}
+}
+
+func TestNewRand(t *testing.T) {
+	draw := func(seed int64, player uint8) []int {
+		rng := NewRand(seed, player)
+		numbers := make([]int, 10)
+		for i := range numbers {
+			numbers[i] = rng.Intn(1000)
+		}
+		return numbers
+	}
+	if !reflect.DeepEqual(draw(7, 0), draw(7, 0)) {
+		t.Error("the same seed drew different numbers")
+	}
+	if reflect.DeepEqual(draw(7, 0), draw(7, 1)) {
+		t.Error("two players drew the same numbers")
+	}
+	if reflect.DeepEqual(draw(7, 0), draw(8, 0)) {
+		t.Error("nearby seeds drew the same numbers")
+	}
+	if NextSeed(7) != NextSeed(7) || NextSeed(7) == 7 {
+		t.Error("NextSeed is not a fixed function of the seed")
+	}
+}
+
+func TestValidateBoard(t *testing.T) {
+	tests := []struct {
+		name  string
+		board [][]uint8
+		ok    bool
+	}{
+		{"valid", rows, true},
+		{"missing row", rows[:2], false},
+		{"short row", [][]uint8{{1, 2, 3}, {4, 5}, {6, 7, 8}}, false},
+		{"repeated", [][]uint8{{1, 2, 3}, {4, 5, 6}, {7, 8, 8}}, false},
+		{"zero", [][]uint8{{0, 2, 3}, {4, 5, 6}, {7, 8, 9}}, false},
+		{"too big", [][]uint8{{10, 2, 3}, {4, 5, 6}, {7, 8, 9}}, false},
+	}
+	for _, tt := range tests {
+		if err := ValidateBoard(tt.board, 3); (err == nil) != tt.ok {
+			t.Errorf("%s: got %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package engine
+
+import "math/rand"
+
+// mix scrambles x so that nearby seeds give unrelated sequences.
+func mix(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+// NextSeed derives the seed of the next round from the seed of the last one,
+// so every round of a game can be regenerated from the first seed.
+func NextSeed(seed int64) int64 {
+	return int64(mix(uint64(seed)))
+}
+
+// NewRand returns the random source a player draws their board and choices
+// from in a round played with seed. Player 0 is the server, which draws the
+// moves made for players who ran out of time.
+func NewRand(seed int64, player uint8) *rand.Rand {
+	return rand.New(rand.NewSource(int64(mix(uint64(seed) ^ mix(uint64(player))))))
+}
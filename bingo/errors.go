@@ -0,0 +1,75 @@
+package bingo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jayakrishnan-jayu/bin-go/bingo/engine"
+)
+
+// Error codes sent to clients with ErrorCommand.
+const (
+	errorCodeUnknown int = iota
+	ErrorCodeInvalidBoard
+	ErrorCodeIllegalMove
+	ErrorCodeTimedOut
+	// The message was not valid JSON or did not fit its type.
+	ErrorCodeBadJSON
+	// The message type is not one the server knows.
+	ErrorCodeUnknownCommand
+	// The message was written for a protocol version the server does not
+	// speak.
+	ErrorCodeUnsupportedVersion
+	ErrorCodeNotYourTurn
+	// The message is only accepted in the lobby, before the game starts.
+	ErrorCodeLobbyClosed
+	// The client was disconnected after too many errors.
+	ErrorCodeTooManyErrors
+	// Another player in the room already has the name.
+	ErrorCodeNameTaken
+	// The name is empty, too long or not the one the player joined with.
+	ErrorCodeInvalidName
+)
+
+// ProtocolError is a client mistake that is reported back to the client
+// instead of being handled on the server.
+type ProtocolError struct {
+	Code    int
+	Message string
+}
+
+func (e *ProtocolError) Error() string {
+	return e.Message
+}
+
+func newProtocolError(code int, format string, a ...interface{}) *ProtocolError {
+	return &ProtocolError{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+// decodeError describes why Decode rejected a message from a client.
+func decodeError(err error) *ProtocolError {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, ErrUnknownMessage):
+		return newProtocolError(ErrorCodeUnknownCommand, "%v", err)
+	case errors.Is(err, ErrUnsupportedVersion):
+		return newProtocolError(ErrorCodeUnsupportedVersion, "%v", err)
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		return newProtocolError(ErrorCodeBadJSON, "malformed message: %v", err)
+	}
+	return newProtocolError(errorCodeUnknown, "%v", err)
+}
+
+// moveError describes why the engine refused a move.
+func moveError(err error) *ProtocolError {
+	var illegal *engine.IllegalMoveError
+	switch {
+	case errors.As(err, &illegal):
+		return newProtocolError(ErrorCodeIllegalMove, "%v", err)
+	case errors.Is(err, engine.ErrNotPlaying), errors.Is(err, engine.ErrGameOver):
+		return newProtocolError(ErrorCodeNotYourTurn, "%v", err)
+	}
+	return newProtocolError(errorCodeUnknown, "%v", err)
+}
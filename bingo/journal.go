@@ -0,0 +1,155 @@
+package bingo
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jayakrishnan-jayu/bin-go/bingo/engine"
+)
+
+// JournalEntryType says what a line of a game journal records.
+type JournalEntryType string
+
+const (
+	// JournalStart records the rules, seed and players of a round.
+	JournalStart JournalEntryType = "start"
+	// JournalMove records a crossed number.
+	JournalMove JournalEntryType = "move"
+	// JournalLeave records a player leaving during the round.
+	JournalLeave JournalEntryType = "leave"
+	// JournalResult records how the round ended.
+	JournalResult JournalEntryType = "result"
+)
+
+// JournalPlayer is a player and the board they played the round with.
+type JournalPlayer struct {
+	Id    uint8  `json:"id"`
+	Name  string `json:"name"`
+	Board Board  `json:"board"`
+}
+
+// MoveRecord is a move as the engine saw it.
+type MoveRecord struct {
+	PlayerId uint8 `json:"player_id"`
+	Number   uint8 `json:"number"`
+	// Set when the server moved for a player who ran out of time.
+	Auto bool `json:"auto"`
+	// The turn the move was made on, when known.
+	Turn uint64 `json:"turn,omitempty"`
+}
+
+// JournalEntry is one line of a game journal. Only the field for its Type is
+// set.
+type JournalEntry struct {
+	Type JournalEntryType `json:"type"`
+	Time time.Time        `json:"time"`
+
+	Room    string          `json:"room,omitempty"`
+	Config  *GameConfig     `json:"config,omitempty"`
+	Players []JournalPlayer `json:"players,omitempty"`
+
+	Move *MoveRecord `json:"move,omitempty"`
+	// The player that left, for JournalLeave.
+	PlayerId uint8 `json:"player_id,omitempty"`
+
+	Result *GameResult `json:"result,omitempty"`
+}
+
+// journal writes one round to a JSON-lines file. Write errors are logged and
+// stop the journal, they never stop the game.
+type journal struct {
+	file *os.File
+	enc  *json.Encoder
+}
+
+// createJournal starts a journal for a round of room in dir.
+func createJournal(dir, room string) (*journal, error) {
+	name := fmt.Sprintf("%s-%s.jsonl", room, time.Now().Format("20060102-150405.000"))
+	file, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+	return &journal{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+// openJournal carries on writing the journal at path.
+func openJournal(path string) (*journal, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &journal{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+func (j *journal) write(entry JournalEntry) {
+	if j == nil || j.enc == nil {
+		return
+	}
+	entry.Time = time.Now()
+	if err := j.enc.Encode(entry); err != nil {
+		log.Println("journal: ", err)
+		j.enc = nil
+	}
+}
+
+func (j *journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	return j.file.Close()
+}
+
+// ReadJournal reads every entry of a game journal.
+func ReadJournal(r io.Reader) ([]JournalEntry, error) {
+	var entries []JournalEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// startJournal opens the journal of a round that is starting, if the room
+// keeps journals. It expects g.lock to be held.
+func (g *Game) startJournal(config GameConfig, players []engine.Player) {
+	if g.JournalDir == "" {
+		return
+	}
+	j, err := createJournal(g.JournalDir, g.ID)
+	if err != nil {
+		log.Println("journal: ", err)
+		return
+	}
+	g.journal = j
+	entry := JournalEntry{Type: JournalStart, Room: g.ID, Config: &config}
+	for _, p := range players {
+		entry.Players = append(entry.Players, JournalPlayer{Id: p.ID, Name: p.Name, Board: p.Board})
+	}
+	g.journal.write(entry)
+}
+
+// closeJournal records how the round ended and closes its journal. It
+// expects g.lock to be held.
+func (g *Game) closeJournal(result *GameResult) {
+	if result != nil {
+		g.journal.write(JournalEntry{Type: JournalResult, Result: result})
+	}
+	if err := g.journal.Close(); err != nil {
+		log.Println("journal: ", err)
+	}
+	g.journal = nil
+}
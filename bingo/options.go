@@ -0,0 +1,172 @@
+package bingo
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	MinBoardSize = 2
+	// Numbers on a board must fit in a uint8.
+	MaxBoardSize = 15
+)
+
+// TimeoutPolicy decides what happens when a player runs out of time.
+type TimeoutPolicy string
+
+const (
+	// TimeoutSkip passes the turn to the next player.
+	TimeoutSkip TimeoutPolicy = "skip"
+	// TimeoutRandom crosses a random number for the player.
+	TimeoutRandom TimeoutPolicy = "random"
+	// TimeoutEject skips the turn and removes the player after MaxTimeouts
+	// consecutive timeouts.
+	TimeoutEject TimeoutPolicy = "eject"
+)
+
+// EndCondition decides when a game is over.
+type EndCondition string
+
+const (
+	// EndFirst ends the game as soon as one player wins.
+	EndFirst EndCondition = "first"
+	// EndTop ends the game once Finishers players have won.
+	EndTop EndCondition = "top"
+	// EndAll ends the game once every player has won.
+	EndAll EndCondition = "all"
+)
+
+// GameOptions configures the rules of a game.
+type GameOptions struct {
+	// Number of rows and columns on each board.
+	BoardSize uint8
+
+	// Completed lines needed to win.
+	WinLines uint8
+
+	// Whether the two diagonals count as lines.
+	Diagonals bool
+
+	// Maximum players in a room, 0 for no limit.
+	MaxPlayers int
+
+	// When the game is over.
+	EndCondition EndCondition
+
+	// Players that have to win before EndTop ends the game.
+	Finishers int
+
+	// Time each player has to make a move, 0 for no limit.
+	TurnTimeout time.Duration
+
+	// What happens when TurnTimeout runs out.
+	TimeoutPolicy TimeoutPolicy
+
+	// Consecutive timeouts before a player is removed by TimeoutEject.
+	MaxTimeouts int
+
+	// How long a disconnected player's seat is held during a game, 0 to
+	// remove them straight away.
+	ReconnectGrace time.Duration
+
+	// Protocol errors after which a client is disconnected, 0 to never
+	// disconnect clients for errors.
+	MaxErrors int
+
+	// AI players the host can add to a room with addbot, by level.
+	Agents map[string]AgentFactory
+
+	// Seed the first round's boards and random moves are derived from, 0 to
+	// pick one at random.
+	Seed int64
+
+	// Directory a journal of every round is written to, empty to keep none.
+	JournalDir string
+
+	// Directory rounds in progress are saved to when the server shuts down,
+	// and restored from when it starts, empty to save none.
+	SaveDir string
+
+	// Where player stats are kept across restarts, nil to keep none.
+	Stats StatsStore
+
+	// Password players and spectators have to give to enter a room, empty
+	// for none.
+	Password string
+
+	// Users allowed to play and the token each of them has to give, nil to
+	// let anyone in.
+	Users map[string]string
+
+	// Who may connect to the server, shared by every room. nil lets anyone
+	// in from the server's own pages or outside a browser.
+	Admission *Admission
+}
+
+var DefaultGameOptions = GameOptions{
+	BoardSize:     5,
+	WinLines:      5,
+	Diagonals:     true,
+	MaxPlayers:    0,
+	EndCondition:  EndFirst,
+	Finishers:     3,
+	TurnTimeout:   0,
+	TimeoutPolicy: TimeoutSkip,
+	MaxTimeouts:   3,
+
+	ReconnectGrace: time.Minute,
+
+	MaxErrors: 10,
+}
+
+// maxLines returns how many lines a board has.
+func (o GameOptions) maxLines() int {
+	lines := 2 * int(o.BoardSize)
+	if o.Diagonals {
+		lines += 2
+	}
+	return lines
+}
+
+func (o GameOptions) Validate() error {
+	if o.BoardSize < MinBoardSize || o.BoardSize > MaxBoardSize {
+		return fmt.Errorf("board size must be between %d and %d", MinBoardSize, MaxBoardSize)
+	}
+	if o.WinLines < 1 || int(o.WinLines) > o.maxLines() {
+		return fmt.Errorf("lines to win must be between 1 and %d", o.maxLines())
+	}
+	if o.MaxPlayers < 0 {
+		return fmt.Errorf("max players must not be negative")
+	}
+	switch o.EndCondition {
+	case EndFirst, EndAll:
+	case EndTop:
+		if o.Finishers < 1 {
+			return fmt.Errorf("finishers must be at least 1")
+		}
+	default:
+		return fmt.Errorf("end condition must be %s, %s or %s", EndFirst, EndTop, EndAll)
+	}
+	if o.TurnTimeout < 0 {
+		return fmt.Errorf("turn timeout must not be negative")
+	}
+	if o.ReconnectGrace < 0 {
+		return fmt.Errorf("reconnect grace must not be negative")
+	}
+	if o.Admission != nil && o.Admission.MaxPerIP < 0 {
+		return fmt.Errorf("max connections per IP must not be negative")
+	}
+	if o.MaxErrors < 0 {
+		return fmt.Errorf("max errors must not be negative")
+	}
+	switch o.TimeoutPolicy {
+	case TimeoutSkip, TimeoutRandom:
+	case TimeoutEject:
+		if o.MaxTimeouts < 1 {
+			return fmt.Errorf("max timeouts must be at least 1")
+		}
+	default:
+		return fmt.Errorf("timeout policy must be %s, %s or %s", TimeoutSkip, TimeoutRandom, TimeoutEject)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package bingo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"net"
+	"sync/atomic"
+
+	"github.com/jayakrishnan-jayu/bin-go/bingo/engine"
+	"github.com/jayakrishnan-jayu/bin-go/utils"
+)
+
+// ErrOutOfSeats is returned once a room has given every player ID out. IDs
+// are never reused, as the leaderboard and journal know players by them.
+var ErrOutOfSeats = errors.New("the room has run out of seats, start a new room")
+
+// start runs the pumps of the client's current connection. It expects
+// g.lock to be held and the room not to be closing, so Wait cannot miss
+// the pumps.
+func (c *Client) start() {
+	c.game.pumps.Add(1)
+	go c.writePump(c.conn, c.Send)
+	go c.readPump(c.conn)
+}
+
+// greet queues the handshake for a new player, ending with the board they
+// were dealt. It is queued before the pumps start, so a rejected client
+// cannot close Send while it is still being written to.
+func (c *Client) greet() {
+	c.requestPlayerName()
+	c.sendPlayerID()
+	c.sendGameConfig()
+	c.sendBoard()
+}
+
+// Join seats a new player talking over conn while the room is in the lobby.
+// A non-empty name is the player's name for good, otherwise they are asked
+// for one. The game owns conn from then on and closes it when the player
+// leaves; if Join fails, conn is left to the caller.
+func (g *Game) Join(conn PlayerConn, ip net.IP, name string) (*Client, error) {
+	g.lock.Lock()
+	if !g.IsLobbyMode {
+		g.lock.Unlock()
+		return nil, fmt.Errorf("the game has already started")
+	}
+	if g.MaxPlayers > 0 && len(g.clients) >= g.MaxPlayers {
+		g.lock.Unlock()
+		return nil, fmt.Errorf("room is full")
+	}
+	if name != "" && g.nameTaken(name, nil) {
+		g.lock.Unlock()
+		return nil, ErrNameTaken
+	}
+	if g.playerIndex == math.MaxUint8 {
+		g.lock.Unlock()
+		return nil, ErrOutOfSeats
+	}
+	g.playerIndex++
+	c := g.newClient(conn)
+	c.Name = name
+	c.nameLocked = name != ""
+	c.Ip = ip
+	c.token = utils.NewToken()
+	g.deal(c)
+	// Added straight away so nobody else can take the name.
+	g.clients[c] = true
+	g.joined = true
+	g.lock.Unlock()
+
+	select {
+	case g.register <- c:
+	case <-g.quit:
+		return nil, fmt.Errorf("room is closed")
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.closing {
+		return nil, ErrServerClosed
+	}
+	c.greet()
+	c.start()
+	return c, nil
+}
+
+// newClient creates the next player of the room. It expects g.lock to be
+// held.
+func (g *Game) newClient(conn PlayerConn) *Client {
+	return &Client{
+		Id:        g.playerIndex,
+		conn:      conn,
+		game:      g,
+		Send:      make(chan []byte, 256),
+		Connected: true,
+		gone:      make(chan struct{}),
+	}
+}
+
+// Agent plays a seat from inside the server. It is handed every message the
+// seat receives, in order, and answers through the send function it was
+// created with.
+type Agent interface {
+	Handle(payload interface{})
+}
+
+// AgentFactory creates an agent that plays under name. Its random choices
+// are drawn from rng, which is derived from the room's seed so a room
+// replayed with the same seed plays out the same.
+type AgentFactory func(name string, rng *rand.Rand, send func(t MessageType, payload interface{})) Agent
+
+// runAgent plays the other end of conn with an agent. Messages pass
+// through the same encoding as they would on a websocket, so agents see
+// exactly what remote players see.
+func runAgent(conn PlayerConn, name string, rng *rand.Rand, factory AgentFactory) {
+	defer conn.Close()
+	var seq uint64
+	agent := factory(name, rng, func(t MessageType, payload interface{}) {
+		output, err := Encode(t, atomic.AddUint64(&seq, 1), payload)
+		if err != nil {
+			log.Println("agent: ", err)
+			return
+		}
+		conn.Send(output)
+	})
+	for {
+		frame, err := conn.Receive()
+		if err != nil {
+			return
+		}
+		for _, message := range bytes.Split(frame, utils.Newline) {
+			_, payload, err := Decode(message)
+			if err != nil {
+				log.Println("agent: ", err)
+				continue
+			}
+			agent.Handle(payload)
+		}
+	}
+}
+
+// addAgent seats an AI player of the given level. It expects to be called
+// from Run, since it registers the client directly.
+func (g *Game) addAgent(level string) error {
+	factory, ok := g.Agents[level]
+	if !ok {
+		return fmt.Errorf("unknown bot level %q", level)
+	}
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.closing {
+		return ErrServerClosed
+	}
+	if !g.IsLobbyMode {
+		return fmt.Errorf("bots can only join in the lobby")
+	}
+	if g.MaxPlayers > 0 && len(g.clients) >= g.MaxPlayers {
+		return fmt.Errorf("room is full")
+	}
+	if g.playerIndex == math.MaxUint8 {
+		return ErrOutOfSeats
+	}
+	g.playerIndex++
+	local, remote := Pipe()
+	c := g.newClient(local)
+	c.Bot = true
+	c.Name = g.uniqueName(fmt.Sprintf("%s-bot-%d", level, c.Id))
+	c.nameLocked = true
+	g.deal(c)
+	g.clients[c] = true
+	go runAgent(remote, c.Name, engine.NewRand(g.seed, c.Id), factory)
+	c.greet()
+	c.start()
+	return nil
+}
@@ -0,0 +1,31 @@
+package bingo
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestJoinRunsOutOfSeats(t *testing.T) {
+	g := New(nil, DefaultGameOptions)
+	go g.Run()
+	defer g.Stop()
+	g.lock.Lock()
+	g.playerIndex = math.MaxUint8 - 1
+	g.lock.Unlock()
+
+	local, remote := Pipe()
+	defer remote.Close()
+	c, err := g.Join(local, nil, "last")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Id != math.MaxUint8 {
+		t.Errorf("got id %d, want %d", c.Id, math.MaxUint8)
+	}
+	local, remote = Pipe()
+	defer remote.Close()
+	if _, err := g.Join(local, nil, "one-too-many"); !errors.Is(err, ErrOutOfSeats) {
+		t.Errorf("got %v, want ErrOutOfSeats", err)
+	}
+}
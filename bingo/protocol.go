@@ -0,0 +1,132 @@
+package bingo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// Protocol versions this build can speak. Peers negotiate the highest
+// version both support during the websocket handshake.
+const (
+	MinProtocolVersion = 1
+	ProtocolVersion    = 1
+)
+
+// subprotocolPrefix names protocol versions in the Sec-WebSocket-Protocol
+// header, as in "bingo.v1".
+const subprotocolPrefix = "bingo.v"
+
+var (
+	ErrUnknownMessage     = errors.New("unknown message type")
+	ErrUnsupportedVersion = errors.New("unsupported protocol version")
+)
+
+// MessageType identifies the payload carried by an Envelope.
+type MessageType string
+
+// Envelope wraps every message sent between the server and its clients.
+type Envelope struct {
+	V    int         `json:"v"`
+	Type MessageType `json:"type"`
+	// Sequence number of the message, counted by its sender.
+	Seq     uint64          `json:"seq"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// registry maps each message type to the struct its payload decodes into.
+var registry = map[MessageType]func() interface{}{
+	PlayerNameCommand:     func() interface{} { return new(PlayerName) },
+	PlayerIDCommand:       func() interface{} { return new(PlayerID) },
+	PlayersListCommand:    func() interface{} { return new(PlayersList) },
+	GameConfigCommand:     func() interface{} { return new(GameConfig) },
+	PlayerBoardCommand:    func() interface{} { return new(PlayersBoard) },
+	GameStatusCommand:     func() interface{} { return new(GameStatus) },
+	GameMoveCommand:       func() interface{} { return new(GameMove) },
+	GameScoreIndexCommand: func() interface{} { return new(GameScoreIndex) },
+	ErrorCommand:          func() interface{} { return new(Error) },
+	GameTimeoutCommand:    func() interface{} { return new(GameTimeout) },
+	GameStateCommand:      func() interface{} { return new(GameState) },
+	SpectatorViewCommand:  func() interface{} { return new(SpectatorView) },
+	GameResultCommand:     func() interface{} { return new(GameResult) },
+	RoundVoteCommand:      func() interface{} { return new(RoundVote) },
+	RoundVotesCommand:     func() interface{} { return new(RoundVotes) },
+	LeaderboardCommand:    func() interface{} { return new(Leaderboard) },
+	ServerShutdownCommand: func() interface{} { return new(ServerShutdown) },
+}
+
+// Encode wraps payload in an envelope of the given type. A nil payload
+// sends a bare request, such as asking a player for their name.
+func Encode(t MessageType, seq uint64, payload interface{}) ([]byte, error) {
+	if _, ok := registry[t]; !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownMessage, t)
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Envelope{V: ProtocolVersion, Type: t, Seq: seq, Payload: raw})
+}
+
+// Decode reads an envelope and decodes its payload into a pointer to the
+// struct registered for its type.
+func Decode(data []byte) (Envelope, interface{}, error) {
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return env, nil, err
+	}
+	if env.V < MinProtocolVersion || env.V > ProtocolVersion {
+		return env, nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, env.V)
+	}
+	newPayload, ok := registry[env.Type]
+	if !ok {
+		return env, nil, fmt.Errorf("%w: %q", ErrUnknownMessage, env.Type)
+	}
+	payload := newPayload()
+	if len(env.Payload) > 0 {
+		if err := json.Unmarshal(env.Payload, payload); err != nil {
+			return env, nil, err
+		}
+	}
+	return env, payload, nil
+}
+
+// Subprotocols lists the supported protocol versions as websocket
+// subprotocols, newest first.
+func Subprotocols() []string {
+	protocols := make([]string, 0, ProtocolVersion-MinProtocolVersion+1)
+	for v := ProtocolVersion; v >= MinProtocolVersion; v-- {
+		protocols = append(protocols, subprotocolPrefix+strconv.Itoa(v))
+	}
+	return protocols
+}
+
+// ParseSubprotocol returns the protocol version named by a websocket
+// subprotocol.
+func ParseSubprotocol(protocol string) (int, bool) {
+	if !strings.HasPrefix(protocol, subprotocolPrefix) {
+		return 0, false
+	}
+	v, err := strconv.Atoi(strings.TrimPrefix(protocol, subprotocolPrefix))
+	if err != nil || v < MinProtocolVersion || v > ProtocolVersion {
+		return 0, false
+	}
+	return v, true
+}
+
+// checkProtocol refuses a websocket handshake from a peer that speaks none
+// of the supported protocol versions.
+func checkProtocol(w http.ResponseWriter, r *http.Request) bool {
+	for _, protocol := range websocket.Subprotocols(r) {
+		if _, ok := ParseSubprotocol(protocol); ok {
+			return true
+		}
+	}
+	http.Error(w, fmt.Sprintf("Incompatible client: this server speaks bingo protocol versions %d to %d, please upgrade", MinProtocolVersion, ProtocolVersion), http.StatusUpgradeRequired)
+	return false
+}
@@ -5,6 +5,8 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"strconv"
+	"strings"
 	"text/tabwriter"
 )
 
@@ -32,17 +34,43 @@ func (p PlayersList) RenderLobby() {
 	w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 1)
 	fmt.Println("Lobby")
 	for _, p := range p.Players {
+		if p.Bot {
+			fmt.Fprintf(w, "%d)\t%s\t(bot)\n", p.Id, p.Name)
+			continue
+		}
+		if !p.Connected {
+			fmt.Fprintf(w, "%d)\t%s\t(%s)\tdisconnected\n", p.Id, p.Name, p.Ip)
+			continue
+		}
 		fmt.Fprintf(w, "%d)\t%s\t(%s)\n", p.Id, p.Name, p.Ip)
 	}
 	w.Flush()
 }
 
-func RenderBoard(board [][]uint8) {
+func RenderRooms(rooms []RoomInfo) {
+	w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 1)
+	fmt.Println("Rooms")
+	for _, r := range rooms {
+		state := "playing"
+		if r.IsLobbyMode {
+			state = "lobby"
+		}
+		fmt.Fprintf(w, "%s\t%d players\t%d spectators\t%s\n", r.ID, r.Players, r.Spectators, state)
+	}
+	w.Flush()
+}
+
+// RenderBoard prints the board with crossed numbers shown as x.
+func RenderBoard(board [][]uint8, crossed map[uint8]bool) {
 	ClearTerminal()
 	w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 1)
 
 	for _, row := range board {
 		for _, col := range row {
+			if crossed[col] {
+				fmt.Fprintf(w, "x\t")
+				continue
+			}
 			fmt.Fprintf(w, "%d\t", col)
 		}
 		fmt.Fprintf(w, "\n")
@@ -50,14 +78,87 @@ func RenderBoard(board [][]uint8) {
 	w.Flush()
 }
 
-func RenderServerBoard(clients *map[*Client]bool) {
+// RenderSpectatorView prints every player's board and score.
+func RenderSpectatorView(view SpectatorView, winLines uint8) {
 	ClearTerminal()
+	crossed := make(map[uint8]bool, len(view.Crossed))
+	for _, n := range view.Crossed {
+		crossed[n] = true
+	}
 	w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 1)
-	for c := range *clients {
-		fmt.Fprintf(w, "%s\t%d/%d\n", c.Name, c.score, c.game.BoardSize)
+	for _, p := range view.Players {
+		status := ""
+		switch {
+		case p.ScoreIndex > 0:
+			status = fmt.Sprintf("finished #%d", p.ScoreIndex)
+		case p.Id == view.CurrentPlayer:
+			status = "playing"
+		case !p.Connected:
+			status = "disconnected"
+		}
+		fmt.Fprintf(w, "%s\t%d/%d\t%s\n", p.Name, p.Score, winLines, status)
+		if p.Board == nil {
+			fmt.Fprintf(w, "\n")
+			continue
+		}
+		for _, row := range p.Board {
+			for _, col := range row {
+				if crossed[col] {
+					fmt.Fprintf(w, "x\t")
+					continue
+				}
+				fmt.Fprintf(w, "%d\t", col)
+			}
+			fmt.Fprintf(w, "\n")
+		}
+		fmt.Fprintf(w, "\n")
+	}
+	w.Flush()
+}
+
+func RenderStandings(result GameResult) {
+	w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 1)
+	if result.Aborted {
+		fmt.Printf("Round abandoned after %d moves\n", result.Moves)
+	} else {
+		fmt.Printf("Game over after %d moves\n", result.Moves)
+	}
+	for _, s := range result.Standings {
+		status := ""
+		if s.Finished {
+			status = "finished"
+		}
+		fmt.Fprintf(w, "%d)\t%s\t%d lines\t%d moves\t%s\n", s.Rank, s.Name, s.Lines, s.Moves, status)
+	}
+	w.Flush()
+	if result.Round < 2 {
+		return
+	}
+	fmt.Printf("\nLeaderboard after %d rounds\n", result.Round)
+	for i, e := range result.Leaderboard {
+		fmt.Fprintf(w, "%d)\t%s\t%d points\t%d wins\t%d rounds\n", i+1, e.Name, e.Points, e.Wins, e.Rounds)
+	}
+	w.Flush()
+}
+
+// RenderAllTimeLeaderboard prints the stats of every player, best first.
+func RenderAllTimeLeaderboard(players []PlayerStats) {
+	if len(players) == 0 {
+		fmt.Println("No games recorded yet")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 1)
+	fmt.Println("All-time leaderboard")
+	for i, p := range players {
+		places := make([]string, len(p.Places))
+		for j, n := range p.Places {
+			places[j] = strconv.Itoa(n)
+		}
+		average := "-"
+		if p.Wins > 0 {
+			average = fmt.Sprintf("%.1f", p.AverageMovesToWin())
+		}
+		fmt.Fprintf(w, "%d)\t%s\t%d wins\t%d games\t%s moves to win\tplaces %s\n", i+1, p.Name, p.Wins, p.Games, average, strings.Join(places, "/"))
 	}
-	// rows, cols, diags := countFalseRowsColsDiags(*board)
-	// fmt.Fprintf(w, "False Rows: %d, False Columns: %d, False Diagonals: %d\n", 
-	// rows, cols, diags)
 	w.Flush()
 }
@@ -0,0 +1,100 @@
+package bingo
+
+import (
+	"time"
+
+	"github.com/jayakrishnan-jayu/bin-go/bingo/engine"
+)
+
+// standings converts the engine's standings for the clients. It expects
+// g.lock to be held.
+func (g *Game) standings() []Standing {
+	ranked := g.state.Standings()
+	standings := make([]Standing, len(ranked))
+	for i, s := range ranked {
+		standings[i] = Standing{
+			Rank:     s.Rank,
+			Id:       s.ID,
+			Name:     s.Name,
+			Lines:    s.Lines,
+			Moves:    s.Moves,
+			Finished: s.Finished(),
+		}
+	}
+	return standings
+}
+
+// endGame broadcasts the final standings and puts the room back into lobby
+// mode, ready for a rematch with the players still connected. Standings of
+// an aborted round are not added to the leaderboard.
+func (g *Game) endGame(aborted bool) {
+	g.lock.Lock()
+	result := GameResult{
+		Standings: g.standings(),
+		Moves:     len(g.state.Moves),
+		Aborted:   aborted,
+	}
+	bots := make(map[uint8]bool)
+	if !aborted {
+		g.recordRound(result.Standings)
+		for c := range g.clients {
+			bots[c.Id] = c.Bot
+		}
+	}
+	result.Round = g.rounds
+	result.Leaderboard = g.sortedLeaderboard()
+	g.closeJournal(&result)
+	g.resetRound()
+	config := g.publicConfig()
+	boards := make(map[*Client]Board, len(g.clients))
+	for c := range g.clients {
+		boards[c] = c.board
+	}
+	g.lock.Unlock()
+
+	g.send(g.encode(GameResultCommand, result))
+	RenderStandings(result)
+	if !aborted {
+		g.recordStats(result.Standings, bots)
+	}
+
+	// Boards for the next round are drawn from its new seed.
+	g.send(g.encode(GameConfigCommand, config))
+	for c, board := range boards {
+		g.sendToPlayer(c, g.encode(PlayerBoardCommand, PlayersBoard{Board: board}))
+	}
+	g.renderLobby()
+
+	// A new round asked for while this one was played starts now, after
+	// everything about this one has been sent.
+	g.lock.Lock()
+	g.maybeStartRound()
+	g.lock.Unlock()
+}
+
+// resetRound clears the crossed numbers and scores, deals new boards, drops
+// players whose seats were only being held, and reopens the lobby. It
+// expects g.lock to be held.
+func (g *Game) resetRound() {
+	// Releases moves still waiting for the play loop. newRound may have
+	// closed it already to abandon the round.
+	select {
+	case <-g.round:
+	default:
+		close(g.round)
+	}
+	g.state = engine.State{}
+	g.seed = engine.NextSeed(g.seed)
+	g.turnPlayer = 0
+	g.turnDeadline = time.Time{}
+	for c := range g.clients {
+		if !c.Connected {
+			g.removeClient(c)
+			continue
+		}
+		g.deal(c)
+		c.timeouts = 0
+		c.votedNewRound = false
+	}
+	g.IsLobbyMode = true
+}
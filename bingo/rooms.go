@@ -0,0 +1,292 @@
+package bingo
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jayakrishnan-jayu/bin-go/utils"
+)
+
+// DefaultRoom is the room players are routed to when no room ID is given.
+const DefaultRoom = "default"
+
+var (
+	ErrRoomExists   = errors.New("room already exists")
+	ErrRoomNotFound = errors.New("room not found")
+	ErrRoomInvalid  = errors.New("invalid room id")
+	ErrServerClosed = errors.New("server is shutting down")
+)
+
+type RoomInfo struct {
+	ID          string `json:"id"`
+	Players     int    `json:"players"`
+	Spectators  int    `json:"spectators"`
+	IsLobbyMode bool   `json:"is_lobby_mode"`
+}
+
+// RoomManager owns every Game running in this process and routes
+// websocket connections to them by room ID.
+type RoomManager struct {
+	serverIp net.IP
+	options  GameOptions
+	lock     sync.RWMutex
+	rooms    map[string]*Game
+	// Set once the server is shutting down.
+	closed bool
+}
+
+func NewRoomManager(serverIp net.IP, options GameOptions) *RoomManager {
+	return &RoomManager{
+		serverIp: serverIp,
+		options:  options,
+		rooms:    make(map[string]*Game),
+	}
+}
+
+func validRoomID(id string) bool {
+	if id == "" || len(id) > utils.MaxRoomIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Create starts a new room with the given ID.
+func (m *RoomManager) Create(id string) (*Game, error) {
+	if !validRoomID(id) {
+		return nil, ErrRoomInvalid
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, ok := m.rooms[id]; ok {
+		return nil, ErrRoomExists
+	}
+	return m.create(id), nil
+}
+
+// create expects m.lock to be held.
+func (m *RoomManager) create(id string) *Game {
+	game := New(m.serverIp, m.options)
+	game.ID = id
+	m.rooms[id] = game
+	go game.Run()
+	return game
+}
+
+func (m *RoomManager) Get(id string) (*Game, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	game, ok := m.rooms[id]
+	return game, ok
+}
+
+// getOrCreate returns the room with the given ID, creating it if needed.
+func (m *RoomManager) getOrCreate(id string) (*Game, error) {
+	if !validRoomID(id) {
+		return nil, ErrRoomInvalid
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.closed {
+		return nil, ErrServerClosed
+	}
+	if game, ok := m.rooms[id]; ok {
+		return game, nil
+	}
+	return m.create(id), nil
+}
+
+// Close stops the room and disconnects all of its players.
+func (m *RoomManager) Close(id string) error {
+	m.lock.Lock()
+	game, ok := m.rooms[id]
+	delete(m.rooms, id)
+	m.lock.Unlock()
+	if !ok {
+		return ErrRoomNotFound
+	}
+	game.Stop()
+	return nil
+}
+
+func (m *RoomManager) List() []RoomInfo {
+	m.lock.RLock()
+	rooms := make([]RoomInfo, 0, len(m.rooms))
+	for _, game := range m.rooms {
+		rooms = append(rooms, game.Info())
+	}
+	m.lock.RUnlock()
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].ID < rooms[j].ID })
+	return rooms
+}
+
+// ServeHTTP routes /ws to the default room and /ws/{room} to the named
+// room, creating the room when a player first joins it.
+func (m *RoomManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/ws"), "/")
+	if id == "" {
+		id = DefaultRoom
+	}
+	// Checked before the room is created, so refused clients cannot open
+	// rooms.
+	if status, err := m.options.Admission.admit(r); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if !checkProtocol(w, r) {
+		return
+	}
+	if seatToken(r) != "" || r.URL.Query().Get("watch") != "" {
+		// Resuming a seat or watching needs a room that is already there.
+		game, ok := m.Get(id)
+		if !ok {
+			http.Error(w, ErrRoomNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		game.ServeHTTP(w, r)
+		return
+	}
+	if !checkPassword(r, m.options.Password) {
+		http.Error(w, "Wrong room password", http.StatusUnauthorized)
+		return
+	}
+	if err := checkUser(r, m.options.Users); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	game, err := m.getOrCreate(id)
+	if errors.Is(err, ErrServerClosed) {
+		http.Error(w, "The server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	game.ServeHTTP(w, r)
+}
+
+// ServeRoomList writes the list of rooms as JSON.
+func (m *RoomManager) ServeRoomList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(m.List()); err != nil {
+		log.Println("ServeRoomList: ", err)
+	}
+}
+
+// collect removes rooms that every player has left, and rooms nobody has
+// joined.
+func (m *RoomManager) collect() {
+	m.lock.Lock()
+	finished := make([]*Game, 0)
+	for id, game := range m.rooms {
+		if game.Finished() {
+			finished = append(finished, game)
+			delete(m.rooms, id)
+		}
+	}
+	m.lock.Unlock()
+	for _, game := range finished {
+		game.Stop()
+		log.Printf("Room %s closed\n", game.ID)
+	}
+}
+
+func (m *RoomManager) readInput(input chan<- string) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		input <- scanner.Text()
+	}
+}
+
+// handleInput runs a host command of the form "<command> [room]", or
+// "addbot <level> [room]".
+func (m *RoomManager) handleInput(line string) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return
+	}
+	cmd, args := fields[0], fields[1:]
+	if cmd == "addbot" && len(args) > 0 {
+		cmd += " " + args[0]
+		args = args[1:]
+	}
+	id := DefaultRoom
+	if len(args) > 0 {
+		id = args[0]
+	}
+	switch fields[0] {
+	case "rooms":
+		RenderRooms(m.List())
+	case "leaderboard":
+		m.renderLeaderboard()
+	case "new":
+		if _, err := m.Create(id); err != nil {
+			fmt.Printf("new %s: %v\n", id, err)
+		}
+	case "close":
+		if err := m.Close(id); err != nil {
+			fmt.Printf("close %s: %v\n", id, err)
+		}
+	case "addbot":
+		// Bots can fill a room nobody has joined yet.
+		game, err := m.getOrCreate(id)
+		if err != nil {
+			fmt.Printf("addbot %s: %v\n", id, err)
+			return
+		}
+		game.Input(cmd)
+	default:
+		game, ok := m.Get(id)
+		if !ok {
+			fmt.Printf("%s: %v\n", id, ErrRoomNotFound)
+			return
+		}
+		game.Input(cmd)
+	}
+}
+
+// renderLeaderboard prints the all-time leaderboard for the host.
+func (m *RoomManager) renderLeaderboard() {
+	if m.options.Stats == nil {
+		fmt.Println("leaderboard: the server keeps no stats, start it with -stats")
+		return
+	}
+	players, err := m.options.Stats.Leaderboard()
+	if err != nil {
+		fmt.Printf("leaderboard: %v\n", err)
+		return
+	}
+	RenderAllTimeLeaderboard(players)
+}
+
+func (m *RoomManager) Run() {
+	input := make(chan string)
+	go m.readInput(input)
+	ticker := time.NewTicker(utils.RoomGCPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case line := <-input:
+			m.handleInput(line)
+		case <-ticker.C:
+			m.collect()
+		}
+	}
+}
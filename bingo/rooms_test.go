@@ -0,0 +1,70 @@
+package bingo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jayakrishnan-jayu/bin-go/utils"
+)
+
+func TestRefusedRequestsOpenNoRoom(t *testing.T) {
+	options := DefaultGameOptions
+	options.Password = "secret"
+	m := NewRoomManager(nil, options)
+	tests := []struct {
+		name   string
+		target string
+		header http.Header
+		ws     bool
+		status int
+	}{
+		{"plain GET", "/ws/plain", nil, false, http.StatusUpgradeRequired},
+		{"wrong password", "/ws/password", AuthHeader("guess", "", ""), true, http.StatusUnauthorized},
+		{"password in the URL", "/ws/query?password=secret", nil, true, http.StatusUnauthorized},
+		{"watch", "/ws/watch?watch=1", AuthHeader("secret", "", ""), true, http.StatusNotFound},
+		{"resume", "/ws/resume", AuthHeader("", "", "abc"), true, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		for key, values := range tt.header {
+			r.Header[key] = values
+		}
+		if tt.ws {
+			r.Header.Set("Sec-Websocket-Protocol", Subprotocols()[0])
+		}
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code != tt.status {
+			t.Errorf("%s: got status %d, want %d", tt.name, w.Code, tt.status)
+		}
+	}
+	if rooms := m.List(); len(rooms) != 0 {
+		t.Errorf("refused requests left rooms behind: %v", rooms)
+	}
+}
+
+func TestCollectEmptyRooms(t *testing.T) {
+	m := NewRoomManager(nil, DefaultGameOptions)
+	fresh, err := m.Create("fresh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale, err := m.Create("stale")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fresh.Stop()
+	stale.lock.Lock()
+	stale.created = time.Now().Add(-utils.EmptyRoomGrace)
+	stale.lock.Unlock()
+
+	m.collect()
+	if _, ok := m.Get("stale"); ok {
+		t.Error("a room nobody joined was kept after the grace period")
+	}
+	if _, ok := m.Get("fresh"); !ok {
+		t.Error("a new room was collected before anyone could join it")
+	}
+}
@@ -0,0 +1,143 @@
+package bingo
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jayakrishnan-jayu/bin-go/bingo/engine"
+)
+
+// startRound starts the play loop. It expects g.lock to be held.
+func (g *Game) startRound() {
+	g.IsLobbyMode = false
+	g.startPending = false
+	g.round = make(chan struct{})
+	players := make([]engine.Player, 0, len(g.clients))
+	for c := range g.clients {
+		c.votedNewRound = false
+		if c.board != nil {
+			players = append(players, engine.Player{ID: c.Id, Name: c.Name, Board: c.board})
+		}
+	}
+	config := g.gameConfig()
+	g.state = engine.New(config.Rules(), players)
+	g.rng = engine.NewRand(g.seed, 0)
+	g.startJournal(config, players)
+	go g.play(0)
+}
+
+// newRound starts another round with the players still connected. A round
+// in progress is abandoned first, and the new one starts once its result has
+// been sent.
+func (g *Game) newRound() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if !g.IsLobbyMode {
+		if !g.startPending {
+			g.startPending = true
+			close(g.round)
+		}
+		return
+	}
+	g.startPending = true
+	g.maybeStartRound()
+}
+
+// maybeStartRound starts a pending round once every connected player has a
+// board. It expects g.lock to be held.
+func (g *Game) maybeStartRound() {
+	if !g.startPending || !g.IsLobbyMode {
+		return
+	}
+	ready := 0
+	for c := range g.clients {
+		if !c.Connected {
+			continue
+		}
+		if c.board == nil {
+			return
+		}
+		ready++
+	}
+	if ready > 0 {
+		g.startRound()
+	}
+}
+
+// voteNewRound records c's vote for a new round, and starts one once more
+// than half of the connected players have voted.
+func (g *Game) voteNewRound(c *Client) error {
+	g.lock.Lock()
+	if !g.IsLobbyMode || g.rounds == 0 {
+		g.lock.Unlock()
+		return newProtocolError(ErrorCodeLobbyClosed, "votes for a new round are only counted between rounds")
+	}
+	c.votedNewRound = true
+	votes, connected := 0, 0
+	for p := range g.clients {
+		if !p.Connected || p.Bot {
+			continue
+		}
+		connected++
+		if p.votedNewRound {
+			votes++
+		}
+	}
+	needed := connected/2 + 1
+	if votes >= needed {
+		g.startPending = true
+		g.maybeStartRound()
+	}
+	g.lock.Unlock()
+
+	g.send(g.encode(RoundVotesCommand, RoundVotes{
+		Votes:  votes,
+		Needed: needed,
+	}))
+	fmt.Printf("%s voted for a new round (%d/%d)\n", c.Name, votes, needed)
+	return nil
+}
+
+// recordRound adds the standings of a finished round to the leaderboard.
+// Each player scores a point for every player ranked below them, plus one
+// for taking part. It expects g.lock to be held.
+func (g *Game) recordRound(standings []Standing) {
+	g.rounds++
+	for _, s := range standings {
+		entry, ok := g.leaderboard[s.Id]
+		if !ok {
+			entry = &LeaderboardEntry{Id: s.Id}
+			g.leaderboard[s.Id] = entry
+		}
+		entry.Name = s.Name
+		entry.Rounds++
+		if s.Rank == 1 {
+			entry.Wins++
+		}
+		for _, other := range standings {
+			if other.Rank > s.Rank {
+				entry.Points++
+			}
+		}
+		entry.Points++
+	}
+}
+
+// sortedLeaderboard expects g.lock to be held.
+func (g *Game) sortedLeaderboard() []LeaderboardEntry {
+	entries := make([]LeaderboardEntry, 0, len(g.leaderboard))
+	for _, entry := range g.leaderboard {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		if a.Wins != b.Wins {
+			return a.Wins > b.Wins
+		}
+		return a.Id < b.Id
+	})
+	return entries
+}
@@ -0,0 +1,129 @@
+package bingo
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// connection identifies one websocket a client was connected with, so a
+// late disconnect from an old socket cannot drop a resumed seat.
+type connection struct {
+	client *Client
+	conn   PlayerConn
+}
+
+// holdSeat keeps a disconnected client in the game for grace. It expects
+// g.lock to be held.
+func (g *Game) holdSeat(c *Client, grace time.Duration) {
+	c.Connected = false
+	close(c.Send)
+	dc := connection{client: c, conn: c.conn}
+	time.AfterFunc(grace, func() {
+		select {
+		case g.expire <- dc:
+		case <-g.quit:
+		}
+	})
+}
+
+// shouldHoldSeat reports whether c keeps its seat after disconnecting. It
+// expects g.lock to be held.
+func (g *Game) shouldHoldSeat(c *Client) bool {
+	if c.Bot || g.MaxErrors > 0 && c.errors >= g.MaxErrors {
+		return false
+	}
+	p, ok := g.state.Player(c.Id)
+	return !g.IsLobbyMode && g.ReconnectGrace > 0 && ok && p.Playing()
+}
+
+// findSeat returns the disconnected client holding token. It expects g.lock
+// to be held.
+func (g *Game) findSeat(token string) (*Client, bool) {
+	for c := range g.clients {
+		if c.token == token && !c.Connected {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// resume reattaches a new websocket to the seat held for token and sends the
+// client everything it needs to carry on playing.
+func (g *Game) resume(w http.ResponseWriter, r *http.Request, token string) {
+	g.lock.RLock()
+	_, ok := g.findSeat(token)
+	g.lock.RUnlock()
+	if !ok {
+		http.Error(w, "There is no seat to resume for this token", http.StatusNotFound)
+		return
+	}
+
+	conn, err := g.upgrade(w, r)
+	if err != nil {
+		log.Println("resume: ", err)
+		return
+	}
+
+	g.lock.Lock()
+	if g.closing {
+		g.lock.Unlock()
+		closeWith(conn, websocket.CloseGoingAway, g.shutdownReason)
+		return
+	}
+	c, ok := g.findSeat(token)
+	if !ok {
+		g.lock.Unlock()
+		closeWith(conn, websocket.ClosePolicyViolation, "the seat has already been resumed")
+		return
+	}
+	c.conn = conn
+	c.Send = make(chan []byte, 256)
+	c.Connected = true
+	c.sendPlayerID()
+	c.queue(PlayersListCommand, g.playerList())
+	c.sendGameConfig()
+	c.queue(PlayerBoardCommand, PlayersBoard{Board: c.board})
+	c.queue(GameStateCommand, g.gameState())
+	if p, ok := g.state.Player(c.Id); ok && p.Finished() {
+		c.queue(GameScoreIndexCommand, GameScoreIndex{Score: p.Place})
+	} else if g.turnPlayer != 0 {
+		c.queue(GameStatusCommand, GameStatus{
+			PlayerId: g.turnPlayer,
+			Turn:     g.turn,
+			TimeLeft: timeLeft(g.turnDeadline),
+		})
+	}
+	c.start()
+	g.lock.Unlock()
+
+	go g.broadcastPlayerlist()
+	log.Printf("%s rejoined room %s\n", c.Name, g.ID)
+}
+
+// queue sends a message to a client whose Send channel nobody else can close
+// at the same time.
+func (c *Client) queue(t MessageType, payload interface{}) {
+	c.Send <- c.game.encode(t, payload)
+}
+
+// gameState expects g.lock to be held.
+func (g *Game) gameState() GameState {
+	crossed := make([]uint8, 0)
+	for n, ok := range g.state.Crossed {
+		if ok {
+			crossed = append(crossed, uint8(n))
+		}
+	}
+	moves := make([]GameMove, len(g.state.Moves))
+	for i, m := range g.state.Moves {
+		p, _ := g.state.Player(m.Player)
+		moves[i] = GameMove{Change: m.Number, Name: p.Name, Auto: m.Auto}
+	}
+	return GameState{
+		Crossed: crossed,
+		Moves:   moves,
+	}
+}
@@ -0,0 +1,315 @@
+package bingo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/gorilla/websocket"
+	"github.com/jayakrishnan-jayu/bin-go/bingo/engine"
+)
+
+// SavedSeat is a player of a saved round.
+type SavedSeat struct {
+	JournalPlayer
+	// Seat token the player resumes with.
+	Token string `json:"token"`
+	Left  bool   `json:"left"`
+	// Moves played when the player left.
+	LeftAfter int  `json:"left_after"`
+	Bot       bool `json:"bot"`
+}
+
+// SavedRoom is a round in progress, saved when the server shuts down so its
+// players can resume it once the server is back.
+type SavedRoom struct {
+	Room   string       `json:"room"`
+	Config GameConfig   `json:"config"`
+	Seats  []SavedSeat  `json:"seats"`
+	Moves  []MoveRecord `json:"moves"`
+	// The player whose turn it was, who moves first once the round resumes.
+	TurnPlayer  uint8              `json:"turn_player"`
+	Turn        uint64             `json:"turn"`
+	Rounds      int                `json:"rounds"`
+	Leaderboard []LeaderboardEntry `json:"leaderboard"`
+	// Journal of the round, which carries on after the restart.
+	Journal string `json:"journal,omitempty"`
+}
+
+func savePath(dir, room string) string {
+	return filepath.Join(dir, room+".json")
+}
+
+// save writes the round in progress to SaveDir. It expects g.lock to be
+// held.
+func (g *Game) save() error {
+	saved := SavedRoom{
+		Room:        g.ID,
+		Config:      g.gameConfig(),
+		TurnPlayer:  g.turnPlayer,
+		Turn:        g.turn,
+		Rounds:      g.rounds,
+		Leaderboard: g.sortedLeaderboard(),
+	}
+	clients := make(map[uint8]*Client, len(g.clients))
+	for c := range g.clients {
+		clients[c.Id] = c
+	}
+	for _, p := range g.state.Players {
+		seat := SavedSeat{
+			JournalPlayer: JournalPlayer{Id: p.ID, Name: p.Name, Board: p.Board},
+			Left:          p.Left,
+			LeftAfter:     p.LeftAfter,
+		}
+		if c, ok := clients[p.ID]; ok {
+			seat.Token = c.token
+			seat.Bot = c.Bot
+		} else if !seat.Left {
+			seat.Left, seat.LeftAfter = true, len(g.state.Moves)
+		}
+		saved.Seats = append(saved.Seats, seat)
+	}
+	for _, m := range g.state.Moves {
+		saved.Moves = append(saved.Moves, MoveRecord{PlayerId: m.Player, Number: m.Number, Auto: m.Auto})
+	}
+	if g.journal != nil {
+		saved.Journal = g.journal.file.Name()
+	}
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	// The file holds seat tokens.
+	return os.WriteFile(savePath(g.SaveDir, g.ID), data, 0600)
+}
+
+// restore sets up a room to carry on with a saved round. Players get their
+// seats held for ReconnectGrace to resume them, or the default grace if
+// seats are not held on this server. Bots are not restored and count as
+// having left.
+func (g *Game) restore(saved SavedRoom) error {
+	rules := saved.Config.Rules()
+	players := make([]engine.Player, len(saved.Seats))
+	for i, seat := range saved.Seats {
+		// Saves are only read back, so a board that was not dealt here has
+		// been tampered with or mangled.
+		if err := engine.ValidateBoard(seat.Board, int(rules.Size)); err != nil {
+			return fmt.Errorf("seat %d: %w", seat.Id, err)
+		}
+		players[i] = engine.Player{ID: seat.Id, Name: seat.Name, Board: seat.Board}
+	}
+	state := engine.New(rules, players)
+	for i, m := range saved.Moves {
+		// Players who left are not scored for the moves after.
+		for _, seat := range saved.Seats {
+			if seat.Left && seat.LeftAfter == i {
+				state = state.Leave(seat.Id)
+			}
+		}
+		next, _, err := state.Apply(engine.Move{Player: m.PlayerId, Number: m.Number, Auto: m.Auto})
+		if err != nil {
+			return fmt.Errorf("move %d: %w", i+1, err)
+		}
+		state = next
+	}
+	for _, seat := range saved.Seats {
+		if seat.Left || seat.Bot {
+			state = state.Leave(seat.Id)
+		}
+	}
+	if state.Over() {
+		return fmt.Errorf("the round is already over")
+	}
+
+	grace := g.ReconnectGrace
+	if grace == 0 {
+		grace = DefaultGameOptions.ReconnectGrace
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	// The round carries on with the rules it was started with.
+	g.BoardSize = saved.Config.BoardSize
+	g.WinLines = saved.Config.WinLines
+	g.Diagonals = saved.Config.Diagonals
+	g.EndCondition = saved.Config.EndCondition
+	g.Finishers = saved.Config.Finishers
+	g.IsLobbyMode = false
+	g.state = state
+	g.seed = saved.Config.Seed
+	g.rng = engine.NewRand(g.seed, 0)
+	g.turn = saved.Turn
+	g.rounds = saved.Rounds
+	for i := range saved.Leaderboard {
+		entry := saved.Leaderboard[i]
+		g.leaderboard[entry.Id] = &entry
+	}
+	for _, seat := range saved.Seats {
+		if seat.Id > g.playerIndex {
+			g.playerIndex = seat.Id
+		}
+		if seat.Left || seat.Bot {
+			continue
+		}
+		c := g.newClient(nil)
+		c.Id = seat.Id
+		c.Name = seat.Name
+		c.nameLocked = true
+		c.token = seat.Token
+		c.board = seat.Board
+		g.clients[c] = true
+		g.joined = true
+		g.holdSeat(c, grace)
+	}
+	if g.JournalDir != "" && saved.Journal != "" {
+		j, err := openJournal(saved.Journal)
+		if err != nil {
+			log.Println("journal: ", err)
+		}
+		g.journal = j
+	}
+	g.round = make(chan struct{})
+	go g.play(saved.TurnPlayer)
+	return nil
+}
+
+// Shutdown tells everyone in the room that the server is going down with
+// reason, saves the round in progress if SaveDir is set, and stops the room.
+// Connections are closed once the message has been sent; Wait returns when
+// they are.
+func (g *Game) Shutdown(reason string) {
+	g.lock.Lock()
+	saved := false
+	if g.SaveDir != "" && !g.IsLobbyMode {
+		if err := g.save(); err != nil {
+			log.Printf("Room %s: could not save the round: %v\n", g.ID, err)
+		} else {
+			saved = true
+		}
+	}
+	// Players dropped by the shutdown have not left the round.
+	g.closeJournal(nil)
+	g.shutdownReason = reason
+	g.closing = true
+	message := g.encode(ServerShutdownCommand, ServerShutdown{Reason: reason, Resumable: saved})
+	for c := range g.clients {
+		if !c.Connected {
+			continue
+		}
+		select {
+		case c.Send <- message:
+		default:
+		}
+	}
+	for c := range g.spectators {
+		select {
+		case c.Send <- message:
+		default:
+		}
+	}
+	g.lock.Unlock()
+	g.Stop()
+}
+
+// Wait blocks until every connection of the room has been closed.
+func (g *Game) Wait() {
+	g.pumps.Wait()
+}
+
+// closeConn closes conn once everything queued for c has been sent, with
+// the reason the server is shutting down if it is.
+func (c *Client) closeConn(conn PlayerConn) {
+	c.game.lock.RLock()
+	reason := c.game.shutdownReason
+	c.game.lock.RUnlock()
+	if reason == "" {
+		conn.Close()
+		return
+	}
+	closeWith(conn, websocket.CloseGoingAway, reason)
+}
+
+// Restore brings back the rounds saved in SaveDir by the last shutdown,
+// and returns the rooms it restored. Save files are removed once restored,
+// and left alone if they cannot be.
+func (m *RoomManager) Restore() ([]string, error) {
+	if m.options.SaveDir == "" {
+		return nil, nil
+	}
+	paths, err := filepath.Glob(savePath(m.options.SaveDir, "*"))
+	if err != nil {
+		return nil, err
+	}
+	var restored []string
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return restored, err
+		}
+		var saved SavedRoom
+		if err := json.Unmarshal(data, &saved); err != nil {
+			log.Printf("%s: %v\n", path, err)
+			continue
+		}
+		if !validRoomID(saved.Room) {
+			log.Printf("%s: %v\n", path, ErrRoomInvalid)
+			continue
+		}
+		m.lock.Lock()
+		if _, ok := m.rooms[saved.Room]; ok {
+			m.lock.Unlock()
+			log.Printf("%s: %v\n", path, ErrRoomExists)
+			continue
+		}
+		game := New(m.serverIp, m.options)
+		game.ID = saved.Room
+		if err := game.restore(saved); err != nil {
+			m.lock.Unlock()
+			game.Stop()
+			log.Printf("%s: %v\n", path, err)
+			continue
+		}
+		m.rooms[saved.Room] = game
+		go game.Run()
+		m.lock.Unlock()
+		if err := os.Remove(path); err != nil {
+			log.Println(err)
+		}
+		restored = append(restored, saved.Room)
+	}
+	return restored, nil
+}
+
+// Shutdown stops every room with reason and refuses new connections. It
+// returns once every connection has been closed, or with ctx's error if
+// that takes too long.
+func (m *RoomManager) Shutdown(ctx context.Context, reason string) error {
+	m.lock.Lock()
+	m.closed = true
+	rooms := make([]*Game, 0, len(m.rooms))
+	for id, game := range m.rooms {
+		rooms = append(rooms, game)
+		delete(m.rooms, id)
+	}
+	m.lock.Unlock()
+
+	for _, game := range rooms {
+		game.Shutdown(reason)
+	}
+	done := make(chan struct{})
+	go func() {
+		for _, game := range rooms {
+			game.Wait()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,104 @@
+package bingo
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/jayakrishnan-jayu/bin-go/bingo/engine"
+)
+
+func TestSaveRestoreKeepsWhenPlayersLeft(t *testing.T) {
+	options := DefaultGameOptions
+	options.BoardSize = 3
+	options.WinLines = 1
+	options.Diagonals = false
+	options.EndCondition = EndFirst
+	options.SaveDir = t.TempDir()
+	g := New(nil, options)
+	g.ID = "saved"
+
+	// Only the row 1 2 3 of the first board is a line of any board.
+	boards := map[uint8]Board{
+		1: {{1, 2, 3}, {4, 5, 6}, {7, 8, 9}},
+		2: {{2, 9, 4}, {7, 1, 6}, {5, 8, 3}},
+		3: {{2, 9, 4}, {7, 1, 6}, {5, 8, 3}},
+	}
+	var players []engine.Player
+	for id := uint8(1); id <= 3; id++ {
+		players = append(players, engine.Player{ID: id, Board: boards[id]})
+	}
+	g.state = engine.New(g.gameConfig().Rules(), players)
+	move := func(player, number uint8) {
+		t.Helper()
+		state, _, err := g.state.Apply(engine.Move{Player: player, Number: number})
+		if err != nil {
+			t.Fatal(err)
+		}
+		g.state = state
+	}
+	move(1, 1)
+	move(2, 2)
+	// The first player leaves before 3 completes their row, which must not
+	// finish them and so end the round.
+	g.state = g.state.Leave(1)
+	move(3, 3)
+	move(2, 4)
+	for _, id := range []uint8{2, 3} {
+		c := g.newClient(nil)
+		c.Id = id
+		c.token = "token"
+		c.board = boards[id]
+		g.clients[c] = true
+	}
+	g.IsLobbyMode = false
+	g.turnPlayer = 3
+	g.lock.Lock()
+	err := g.save()
+	g.lock.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(savePath(options.SaveDir, g.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved SavedRoom
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	restored := New(nil, options)
+	defer restored.Stop()
+	if err := restored.restore(saved); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	restored.lock.RLock()
+	defer restored.lock.RUnlock()
+	p, _ := restored.state.Player(1)
+	if !p.Left || p.LeftAfter != 2 || p.Finished() {
+		t.Errorf("the player who left was restored as %+v", p)
+	}
+	if restored.state.Over() {
+		t.Error("the restored round is over")
+	}
+	if len(restored.state.Moves) != 4 {
+		t.Errorf("restored %d moves, want 4", len(restored.state.Moves))
+	}
+}
+
+func TestRestoreRefusesMangledBoards(t *testing.T) {
+	saved := SavedRoom{
+		Room:   "mangled",
+		Config: GameConfig{BoardSize: 2, WinLines: 1, EndCondition: EndFirst},
+		Seats: []SavedSeat{
+			{JournalPlayer: JournalPlayer{Id: 1, Name: "a", Board: Board{{1, 2}, {3, 4}}}},
+			{JournalPlayer: JournalPlayer{Id: 2, Name: "b", Board: Board{{1, 1}, {1, 1}}}},
+		},
+	}
+	g := New(nil, DefaultGameOptions)
+	defer g.Stop()
+	if err := g.restore(saved); err == nil {
+		t.Error("a board repeating its numbers was restored")
+	}
+}
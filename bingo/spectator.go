@@ -0,0 +1,87 @@
+package bingo
+
+import (
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/websocket"
+)
+
+// spectate connects a read-only client that can join at any time. It sees
+// every player's board and score but is never given a turn.
+func (g *Game) spectate(w http.ResponseWriter, r *http.Request) {
+	conn, err := g.upgrade(w, r)
+	if err != nil {
+		log.Println("spectate: ", err)
+		return
+	}
+	c := &Client{
+		Ip:        remoteIP(r),
+		conn:      conn,
+		game:      g,
+		Send:      make(chan []byte, 256),
+		Connected: true,
+		spectator: true,
+		gone:      make(chan struct{}),
+	}
+
+	g.lock.Lock()
+	select {
+	case <-g.quit:
+		g.lock.Unlock()
+		c.conn.Close()
+		return
+	default:
+	}
+	if g.closing {
+		g.lock.Unlock()
+		closeWith(c.conn, websocket.CloseGoingAway, g.shutdownReason)
+		return
+	}
+	g.spectators[c] = true
+	c.queue(PlayersListCommand, g.playerList())
+	c.sendGameConfig()
+	c.queue(GameStateCommand, g.gameState())
+	c.queue(SpectatorViewCommand, g.spectatorView())
+	c.start()
+	g.lock.Unlock()
+}
+
+// removeSpectator expects g.lock to be held.
+func (g *Game) removeSpectator(c *Client) {
+	if _, ok := g.spectators[c]; ok {
+		close(c.Send)
+		close(c.gone)
+		delete(g.spectators, c)
+	}
+}
+
+// spectatorView expects g.lock to be held.
+func (g *Game) spectatorView() SpectatorView {
+	players := make([]PlayerView, 0, len(g.clients))
+	for c := range g.clients {
+		p, _ := g.state.Player(c.Id)
+		players = append(players, PlayerView{
+			Id:         c.Id,
+			Name:       c.Name,
+			Board:      c.board,
+			Score:      p.Lines,
+			ScoreIndex: p.Place,
+			Connected:  c.Connected,
+		})
+	}
+	sort.Slice(players, func(i, j int) bool { return players[i].Id < players[j].Id })
+	return SpectatorView{
+		Players:       players,
+		Crossed:       g.gameState().Crossed,
+		CurrentPlayer: g.turnPlayer,
+	}
+}
+
+func (g *Game) broadcastSpectatorView() {
+	g.lock.RLock()
+	view := g.spectatorView()
+	g.lock.RUnlock()
+	g.sendTo(spectators, g.encode(SpectatorViewCommand, view))
+}
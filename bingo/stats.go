@@ -0,0 +1,173 @@
+package bingo
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// PlayerStats is what a username has done across every room and restart.
+type PlayerStats struct {
+	Name  string `json:"name"`
+	Games int    `json:"games"`
+	Wins  int    `json:"wins"`
+	// Games the player ended in each place, the first entry counts first
+	// places.
+	Places []int `json:"places"`
+	// Moves made across the games the player won.
+	WinningMoves int `json:"winning_moves"`
+}
+
+// AverageMovesToWin returns the moves the player needs to win a game, 0 if
+// they have never won.
+func (s PlayerStats) AverageMovesToWin() float64 {
+	if s.Wins == 0 {
+		return 0
+	}
+	return float64(s.WinningMoves) / float64(s.Wins)
+}
+
+// StatsStore keeps player stats across rooms and server restarts. It is
+// shared by every room, so it has to be safe for concurrent use.
+type StatsStore interface {
+	// Record adds the standings of a finished round.
+	Record(standings []Standing) error
+	// Leaderboard returns the stats of every player, best first.
+	Leaderboard() ([]PlayerStats, error)
+}
+
+// FileStats is a StatsStore kept in a JSON file, which is rewritten after
+// every round.
+type FileStats struct {
+	path    string
+	lock    sync.Mutex
+	players map[string]*PlayerStats
+}
+
+// OpenFileStats loads the stats kept in path, which is created on the first
+// recorded round if it does not exist yet.
+func OpenFileStats(path string) (*FileStats, error) {
+	s := &FileStats{path: path, players: make(map[string]*PlayerStats)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var players []PlayerStats
+	if err := json.Unmarshal(data, &players); err != nil {
+		return nil, err
+	}
+	for i := range players {
+		s.players[players[i].Name] = &players[i]
+	}
+	return s, nil
+}
+
+func (s *FileStats) Record(standings []Standing) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, standing := range standings {
+		p, ok := s.players[standing.Name]
+		if !ok {
+			p = &PlayerStats{Name: standing.Name}
+			s.players[standing.Name] = p
+		}
+		p.Games++
+		for len(p.Places) < standing.Rank {
+			p.Places = append(p.Places, 0)
+		}
+		p.Places[standing.Rank-1]++
+		if standing.Rank == 1 {
+			p.Wins++
+			p.WinningMoves += standing.Moves
+		}
+	}
+	return s.save()
+}
+
+func (s *FileStats) Leaderboard() ([]PlayerStats, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.sorted(), nil
+}
+
+// sorted ranks players by wins, then by how few moves they need to win. It
+// expects s.lock to be held.
+func (s *FileStats) sorted() []PlayerStats {
+	players := make([]PlayerStats, 0, len(s.players))
+	for _, p := range s.players {
+		players = append(players, *p)
+	}
+	sort.Slice(players, func(i, j int) bool {
+		a, b := players[i], players[j]
+		if a.Wins != b.Wins {
+			return a.Wins > b.Wins
+		}
+		if a.AverageMovesToWin() != b.AverageMovesToWin() {
+			return a.AverageMovesToWin() < b.AverageMovesToWin()
+		}
+		return a.Name < b.Name
+	})
+	return players
+}
+
+// save writes the stats to a temporary file first, so a crash cannot leave
+// the file half written. It expects s.lock to be held.
+func (s *FileStats) save() error {
+	data, err := json.MarshalIndent(s.sorted(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// recordStats adds the players of a finished round to the Stats store, if
+// the server keeps one. Bots are left out.
+func (g *Game) recordStats(standings []Standing, bots map[uint8]bool) {
+	if g.Stats == nil {
+		return
+	}
+	players := make([]Standing, 0, len(standings))
+	for _, s := range standings {
+		if !bots[s.Id] && s.Name != "" {
+			players = append(players, s)
+		}
+	}
+	if len(players) == 0 {
+		return
+	}
+	if err := g.Stats.Record(players); err != nil {
+		log.Println("stats: ", err)
+	}
+}
+
+// sendLeaderboard sends c the all-time leaderboard.
+func (c *Client) sendLeaderboard() {
+	var players []PlayerStats
+	if c.game.Stats != nil {
+		var err error
+		if players, err = c.game.Stats.Leaderboard(); err != nil {
+			log.Println("stats: ", err)
+		}
+	}
+	c.game.sendToClient(c, c.game.encode(LeaderboardCommand, Leaderboard{Players: players}))
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jayakrishnan-jayu/bin-go/bingo"
+	"github.com/jayakrishnan-jayu/bin-go/bingo/bot"
+	"github.com/jayakrishnan-jayu/bin-go/utils"
+)
+
+var serverIp = flag.String("i", "localhost", "Ip Address of Server")
+var port = flag.Int("p", 8080, "Port address of the server")
+var username = flag.String("u", "bot", "Username for the bot, numbered when running more than one")
+var room = flag.String("r", bingo.DefaultRoom, "Room to join on the server")
+var strategy = flag.String("strategy", "greedy", "How the bot plays: "+strings.Join(bot.StrategyNames(), ", "))
+var think = flag.Duration("think", bot.DefaultThink, "How long the bot waits before each move")
+var vote = flag.Bool("vote", false, "Vote for a new round after every game")
+var count = flag.Int("n", 1, "Number of bots to run")
+var password = flag.String("password", "", "Password of the room, if it has one")
+var useTLS = flag.Bool("tls", false, "Connect over TLS (wss://)")
+var caFile = flag.String("ca", "", "Certificate to trust for the server, such as its self-signed one, implies -tls")
+var insecure = flag.Bool("insecure", false, "Do not verify the server certificate, implies -tls")
+var fingerprint = flag.String("fingerprint", "", "SHA-256 fingerprint the server certificate must have, implies -tls")
+
+func main() {
+	flag.Parse()
+
+	s, err := bot.ParseStrategy(*strategy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	options := bot.Options{
+		Strategy: s,
+		Think:    *think,
+		Vote:     *vote,
+	}
+	scheme := "ws"
+	var tlsConfig *tls.Config
+	if *useTLS || *caFile != "" || *insecure || *fingerprint != "" {
+		scheme = "wss"
+		if tlsConfig, err = utils.ClientTLSConfig(*caFile, *insecure, *fingerprint); err != nil {
+			log.Fatal("tls: ", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 1; i <= *count; i++ {
+		name := *username
+		if *count > 1 {
+			name = fmt.Sprintf("%s-%d", *username, i)
+		}
+		query := url.Values{"name": {name}}
+		u := url.URL{
+			Scheme:   scheme,
+			Host:     net.JoinHostPort(*serverIp, strconv.Itoa(*port)),
+			Path:     "/ws/" + *room,
+			RawQuery: query.Encode(),
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := bot.Connect(u.String(), name, bingo.AuthHeader(*password, "", ""), tlsConfig, options); err != nil {
+				log.Printf("%s: %v\n", name, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
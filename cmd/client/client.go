@@ -1,15 +1,19 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
-	"math/rand"
+	"math"
+	"net"
 	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -20,21 +24,52 @@ import (
 var serverIp = flag.String("i", "localhost", "Ip Address of Server")
 var port = flag.Int("p", 8080, "Port address of the server")
 var username = flag.String("u", "user", "Username for game session")
-
-type Client bingo.Client
+var room = flag.String("r", bingo.DefaultRoom, "Room to join on the server")
+var watch = flag.Bool("watch", false, "Watch the game in the room without playing")
+var resume = flag.Bool("resume", false, "Rejoin the game this username was disconnected from")
+var password = flag.String("password", "", "Password of the room, if it has one")
+var authToken = flag.String("auth", "", "Your token, if the server only lets in known users")
+var useTLS = flag.Bool("tls", false, "Connect over TLS (wss://)")
+var caFile = flag.String("ca", "", "Certificate to trust for the server, such as its self-signed one, implies -tls")
+var discover = flag.Bool("discover", false, "Find servers announcing themselves on the LAN and pick a room, instead of -i, -p and -r")
+var discoverWait = flag.Duration("discover-wait", 3*time.Second, "How long -discover listens for servers")
+var insecure = flag.Bool("insecure", false, "Do not verify the server certificate, implies -tls")
+var fingerprint = flag.String("fingerprint", "", "SHA-256 fingerprint the server certificate must have, implies -tls")
+
+type Client struct {
+	Id   uint8
+	Name string
+	Conn *websocket.Conn
+	Send chan []byte
+}
 type GameConfig bingo.GameConfig
 
 type Game struct {
 	gameConfig GameConfig
-	board      *[][]uint8
+	board      bingo.Board
 	started    bool
+	crossed    map[uint8]bool
 }
 
 var game Game
 var players map[int]string
 var finished bool
+
+// Set once the server says it is shutting down.
+var shutdown *bingo.ServerShutdown
+var lastError string
 var gameLog *GameLog
 
+// Lines typed by the player, read by a single goroutine so a turn that
+// times out does not leave a reader behind.
+var inputs chan string
+
+// Closed when the current turn is over.
+var turnDone chan struct{}
+
+// Sequence number of the last message sent to the server.
+var sendSeq uint64
+
 var done chan struct{}
 var interrupt chan os.Signal
 
@@ -84,7 +119,7 @@ func (c *Client) readPump() {
 	defer func() {
 		c.Conn.Close()
 	}()
-	c.Conn.SetReadLimit(utils.MaxMessageSize)
+	c.Conn.SetReadLimit(utils.MaxServerMessageSize)
 	c.Conn.SetReadDeadline(time.Now().Add(utils.PongWait))
 	c.Conn.SetPongHandler(func(string) error {
 		c.Conn.SetReadDeadline(time.Now().Add(utils.PongWait))
@@ -96,18 +131,14 @@ func (c *Client) readPump() {
 			break
 		}
 		for _, message := range messages {
-			var messageMap map[string]interface{}
-			if err := json.Unmarshal(message, &messageMap); err != nil {
-				log.Fatal("mesage map readPumb: ", err)
-				return
-			}
-
-			val, err := parseCommandMessage(messageMap)
+			_, payload, err := bingo.Decode(message)
 			if err != nil {
-				log.Fatal("parse command message readPumb: ", err)
-				return
+				// Messages from a newer server are skipped, only a broken
+				// connection ends the game.
+				log.Println("readPump: ", err)
+				continue
 			}
-			c.handleServerCommand(val, message)
+			c.handleServerMessage(payload)
 		}
 	}
 }
@@ -115,7 +146,9 @@ func (c *Client) readPump() {
 func (c *Client) ReadMessages() ([][]byte, bool) {
 	_, message, err := c.Conn.ReadMessage()
 	if err != nil {
-		if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+		if reason := bingo.CloseReason(err); reason != "" && shutdown == nil {
+			fmt.Println("\nThe server closed the connection:", reason)
+		} else if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 			// log.Printf("error: %v", err)
 		} else {
 			// log.Printf("closed: %v", err)
@@ -126,172 +159,291 @@ func (c *Client) ReadMessages() ([][]byte, bool) {
 	return messages, true
 }
 
-func parseCommandMessage(messageMap map[string]interface{}) (int, error) {
-
-	if cmd, ok := messageMap["command"].(float64); ok {
-		return int(cmd), nil
+// send wraps a message for the server in an envelope and queues it.
+func (c *Client) send(t bingo.MessageType, payload interface{}) {
+	output, err := bingo.Encode(t, atomic.AddUint64(&sendSeq, 1), payload)
+	if err != nil {
+		log.Fatal("send: ", err)
 	}
-	// return -1, fmt.Errorf("Invalid command")
-
-	return -1, fmt.Errorf("Command not found")
-
+	c.Send <- output
 }
 
-func (g *Game) generateGameBoard() {
-	rand.Seed(time.Now().UnixNano())
-	addedNumbers := map[uint8]bool{}
-	size := int(g.gameConfig.BoardSize)
-	board := make([][]uint8, size)
-	for i := range board {
-		board[i] = make([]uint8, size)
-		var n uint8
-		for j := range board[i] {
-			for {
-				n = uint8(rand.Intn(size*size) + 1)
-				if addedNumbers[n] {
-					continue
-				}
-				break
-			}
-			addedNumbers[n] = true
-			board[i][j] = n
-		}
+func (gc GameConfig) winCondition() string {
+	if gc.Diagonals {
+		return fmt.Sprintf("Cross %d rows, columns or diagonals to win", gc.WinLines)
 	}
-	g.board = &board
+	return fmt.Sprintf("Cross %d rows or columns to win", gc.WinLines)
 }
 
-func (c *Client) handleServerCommand(cmd int, message []byte) {
-	switch cmd {
-	case bingo.PlayerNameCommand:
-		output, err := json.Marshal(bingo.PlayerName{
-			Command: bingo.PlayerNameCommand,
-			Name:    *username,
-		})
-		if err != nil {
-			log.Fatal("handleServerCommand ", err)
-			break
-		}
+// newRound forgets the finished game so the next one starts from the lobby.
+func (g *Game) newRound() {
+	g.started = false
+	g.board = nil
+	g.crossed = make(map[uint8]bool)
+	finished = false
+	lastError = ""
+	gameLog = &GameLog{}
+}
 
-		c.Send <- output
-	case bingo.PlayerIDCommand:
-		var playerId bingo.PlayerID
-		err := json.Unmarshal(message, &playerId)
-		if err != nil {
-			log.Fatal("handleServerCommand ", err)
-			break
-		}
-		c.Id = playerId.ID
-	case bingo.PlayersListCommand:
-		var playersList bingo.PlayersList
-		err := json.Unmarshal(message, &playersList)
-		if err != nil {
-			log.Fatal("handleServerCommand ", err)
-			break
+func (c *Client) handleServerMessage(payload interface{}) {
+	switch msg := payload.(type) {
+	case *bingo.PlayerName:
+		c.send(bingo.PlayerNameCommand, bingo.PlayerName{Name: *username})
+	case *bingo.PlayerID:
+		c.Id = msg.ID
+		if err := saveSession(msg.Token); err != nil {
+			log.Println("save session: ", err)
 		}
+	case *bingo.PlayersList:
 		for k := range players {
 			delete(players, k)
 		}
-		for _, c2 := range playersList.Players {
-			players[int(c2.Id)] = c2.Name	
+		for _, c2 := range msg.Players {
+			players[int(c2.Id)] = c2.Name
 		}
-		playersList.RenderLobby()
-	case bingo.GameConfigCommand:
-		err := json.Unmarshal(message, &game.gameConfig)
-		if err != nil {
-			log.Fatal("handleServerCommand ", err)
-			break
+		if !game.started {
+			msg.RenderLobby()
 		}
-	case bingo.PlayerBoardCommand:
-		if game.gameConfig == (GameConfig{}) {
-			log.Fatal("handleServerCommand: GameConfig not yet intilzied")
-		}
-		fmt.Println("Board size", game.gameConfig.BoardSize)
-		game.generateGameBoard()
-		fmt.Println("Board size", len(*game.board))
-		fmt.Printf("%v", game.board)
-		output, err := json.Marshal(bingo.PlayersBoard{
-			Command: bingo.PlayerBoardCommand,
-			Board:   game.board,
-		})
-		if err != nil {
-			log.Fatal("handleServerCommand ", err)
-			break
-		}
-
-		c.Send <- output
-	case bingo.GameStatusCommand:
+	case *bingo.GameConfig:
+		game.gameConfig = GameConfig(*msg)
+	case *bingo.PlayersBoard:
+		// The board the server dealt us, or sent back when we resume.
+		game.board = msg.Board
+	case *bingo.GameStatus:
 		if finished {
 			break
 		}
 		bingo.ClearTerminal()
-		var gameStatus bingo.GameStatus
-		err := json.Unmarshal(message, &gameStatus)
-		if err != nil {
-			log.Fatal("handleServerCommand ", err)
-			break
+		game.started = true
+		if turnDone != nil {
+			close(turnDone)
 		}
-		bingo.RenderBoard(*game.board)
+		turnDone = make(chan struct{})
+		bingo.RenderBoard(game.board, game.crossed)
+		fmt.Println()
+		fmt.Println(game.gameConfig.winCondition())
 		fmt.Println()
 		gameLog.print()
 		fmt.Println()
-		p, ok := players[int(gameStatus.PlayerId)]
+		p, ok := players[int(msg.PlayerId)]
 		if !ok {
 			panic("player not found from id")
 		}
 		fmt.Println("Current Player: ", p)
-		
-		if gameStatus.PlayerId == c.Id {
-			go func() {
-				fmt.Print("Enter Input: ")
-				var digit int
-				fmt.Scanf("%d", &digit)
-				output, err := json.Marshal(bingo.GameMove{
-					Command: bingo.GameMoveCommand,
-					Change:  uint8(digit),
-				})
-				if err != nil {
-					log.Fatal("handleServerCommand ", err)
-				}
-				c.Send <- output
-			}()
+		// Lines printed below the countdown, which it has to skip over.
+		below := 0
+		if msg.TimeLeft > 0 {
+			fmt.Printf("Time left: %s\n", (time.Duration(msg.TimeLeft) * time.Millisecond).Round(time.Second))
+			below++
 		}
-	case bingo.GameMoveCommand:
-		var gameMove bingo.GameMove
-		err := json.Unmarshal(message, &gameMove)
-		if err != nil {
-			log.Fatal("handleServerCommand ", err)
-			break
+		if lastError != "" {
+			fmt.Println(lastError)
+			lastError = ""
+			below++
 		}
-		gameLog.Push(fmt.Sprintf("%s\t%d", gameMove.Name, gameMove.Change))
-	case bingo.GameScoreIndexCommand:
-		var scoreIndex bingo.GameScoreIndex
-		err := json.Unmarshal(message, &scoreIndex)
-		if err != nil {
-			log.Fatal("handleServerCommand ", err)
+
+		if msg.TimeLeft > 0 {
+			deadline := time.Now().Add(time.Duration(msg.TimeLeft) * time.Millisecond)
+			go renderCountdown(deadline, below, turnDone)
+		}
+		if msg.PlayerId == c.Id {
+			go c.readMove(msg.Turn, turnDone)
+		}
+	case *bingo.GameMove:
+		game.crossed[msg.Change] = true
+		if msg.Auto {
+			gameLog.Push(fmt.Sprintf("%s\t%d (timed out)", msg.Name, msg.Change))
+		} else {
+			gameLog.Push(fmt.Sprintf("%s\t%d", msg.Name, msg.Change))
+		}
+	case *bingo.GameState:
+		for _, n := range msg.Crossed {
+			game.crossed[n] = true
+		}
+		for _, gameMove := range msg.Moves {
+			gameLog.Push(fmt.Sprintf("%s\t%d", gameMove.Name, gameMove.Change))
+		}
+	case *bingo.SpectatorView:
+		if len(msg.Players) == 0 || msg.CurrentPlayer == 0 && !game.started {
 			break
 		}
+		game.started = true
+		bingo.RenderSpectatorView(*msg, game.gameConfig.WinLines)
+		fmt.Println(game.gameConfig.winCondition())
+		fmt.Println()
+		gameLog.print()
+	case *bingo.GameTimeout:
+		if msg.Action == bingo.TimeoutEject {
+			gameLog.Push(fmt.Sprintf("%s\tremoved", msg.Name))
+		} else {
+			gameLog.Push(fmt.Sprintf("%s\tskipped", msg.Name))
+		}
+	case *bingo.GameScoreIndex:
 		bingo.ClearTerminal()
 		finished = true
-		fmt.Printf("You won %d/%d\n\n", scoreIndex.Score, len(players))
-		c.Conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		fmt.Printf("You won %d/%d\n\n", msg.Score, len(players))
+		fmt.Println("Waiting for the game to end")
+	case *bingo.GameResult:
+		if turnDone != nil {
+			close(turnDone)
+			turnDone = nil
+		}
+		bingo.ClearTerminal()
+		bingo.RenderStandings(*msg)
+		fmt.Println()
+		fmt.Println("Waiting for the host to start a new game")
+		game.newRound()
+		if !*watch {
+			turnDone = make(chan struct{})
+			go c.readVote(turnDone)
+		}
+	case *bingo.RoundVotes:
+		fmt.Printf("%d/%d players voted for a new round\n", msg.Votes, msg.Needed)
+	case *bingo.Leaderboard:
+		fmt.Println()
+		bingo.RenderAllTimeLeaderboard(msg.Players)
+	case *bingo.ServerShutdown:
+		if turnDone != nil {
+			close(turnDone)
+			turnDone = nil
+		}
+		shutdown = msg
+		fmt.Printf("\n%s.\n", msg.Reason)
+		if msg.Resumable && !*watch {
+			fmt.Println("The game was saved. Run again with -resume once the server is back to carry on.")
+		}
+	case *bingo.Error:
+		lastError = fmt.Sprintf("Server error: %s", msg.Message)
+		if !game.started || msg.Code == bingo.ErrorCodeTimedOut || msg.Code == bingo.ErrorCodeTooManyErrors {
+			fmt.Println(lastError)
+		}
+	}
+}
+
+func readInputs() {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		inputs <- strings.TrimSpace(scanner.Text())
+	}
+}
+
+// readMove sends the next number the player types as their move for turn,
+// unless the turn ends first.
+func (c *Client) readMove(turn uint64, done <-chan struct{}) {
+	fmt.Print("Enter Input: ")
+	for {
+		select {
+		case line := <-inputs:
+			digit, err := strconv.Atoi(line)
+			if err != nil || digit < 0 || digit > math.MaxUint8 {
+				fmt.Print("Enter a number: ")
+				continue
+			}
+			c.send(bingo.GameMoveCommand, bingo.GameMove{Change: uint8(digit), Turn: turn})
+			return
+		case <-done:
+			return
+		}
+	}
+}
+
+// readVote sends a vote for a new round once the player types r, and asks
+// for the all-time leaderboard when they type l, until the next round
+// starts.
+func (c *Client) readVote(done <-chan struct{}) {
+	fmt.Println("Type r to vote for a new round, or l for the all-time leaderboard")
+	voted := false
+	for {
+		select {
+		case line := <-inputs:
+			switch {
+			case line == "l":
+				c.send(bingo.LeaderboardCommand, bingo.Leaderboard{})
+			case line == "r" && !voted:
+				c.send(bingo.RoundVoteCommand, bingo.RoundVote{})
+				voted = true
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// renderCountdown redraws the time left every second on the line printed
+// `below` lines above the cursor, until the turn is over.
+func renderCountdown(deadline time.Time, below int, done <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			left := time.Until(deadline).Round(time.Second)
+			if left < 0 {
+				left = 0
+			}
+			// Save the cursor, move up, rewrite the line and restore it.
+			fmt.Printf("\0337\033[%dA\r\033[KTime left: %s\0338", below, left)
+		}
 	}
 }
 
 func main() {
 	flag.Parse()
 
-	addr := fmt.Sprintf("%s:%d", *serverIp, *port)
 	interrupt = make(chan os.Signal, 1)
 	signal.Notify(interrupt, os.Interrupt)
 	players = make(map[int]string)
+	inputs = make(chan string)
+	go readInputs()
+	if *discover {
+		discoverServer()
+	}
+	addr := net.JoinHostPort(*serverIp, strconv.Itoa(*port))
 
-	u := url.URL{Scheme: "ws", Host: addr, Path: "/ws"}
+	game.crossed = make(map[uint8]bool)
+
+	scheme := "ws"
+	secure := *useTLS || *caFile != "" || *insecure || *fingerprint != ""
+	if secure {
+		scheme = "wss"
+	}
+	u := url.URL{Scheme: scheme, Host: addr, Path: "/ws/" + *room}
+	query := url.Values{}
+	var seat, auth string
+	if *watch {
+		query.Set("watch", "1")
+	} else if *resume {
+		token, err := loadSession()
+		if err != nil {
+			log.Fatal("resume: no saved session for this game: ", err)
+		}
+		seat = token
+	} else {
+		query.Set("name", *username)
+		auth = *authToken
+	}
+	u.RawQuery = query.Encode()
 	gameLog = &GameLog{}
 	// log.Printf("connecting to %s", u.String())
 
-	c, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	dialer := *websocket.DefaultDialer
+	dialer.Subprotocols = bingo.Subprotocols()
+	if secure {
+		tlsConfig, err := utils.ClientTLSConfig(*caFile, *insecure, *fingerprint)
+		if err != nil {
+			log.Fatal("tls: ", err)
+		}
+		dialer.TLSClientConfig = tlsConfig
+	}
+	c, resp, err := dialer.Dial(u.String(), bingo.AuthHeader(*password, auth, seat))
 	if err != nil {
-		log.Fatal("dial:", err)
+		log.Fatal("dial: ", bingo.DialError(err, resp))
+	}
+	if _, ok := bingo.ParseSubprotocol(c.Subprotocol()); !ok {
+		c.Close()
+		log.Fatalf("dial: incompatible server: it does not speak bingo protocol versions %d to %d", bingo.MinProtocolVersion, bingo.ProtocolVersion)
 	}
 
 	client := &Client{
@@ -314,6 +466,9 @@ func main() {
 	for {
 		select {
 		case <-done:
+			if game.started && !finished && !*watch && shutdown == nil {
+				fmt.Println("\nConnection lost. Run again with -resume to rejoin the game.")
+			}
 			return
 		case <-interrupt:
 			log.Println("interrupt")
@@ -332,9 +487,8 @@ func main() {
 
 }
 
-
 type GameLog struct {
-	items [] string
+	items []string
 }
 
 func (q *GameLog) Push(value string) {
@@ -352,6 +506,6 @@ func (q *GameLog) pop() string {
 
 func (q *GameLog) print() {
 	for _, gm := range (*q).items {
-		fmt.Println(gm)	
+		fmt.Println(gm)
 	}
-}
\ No newline at end of file
+}
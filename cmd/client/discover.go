@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/jayakrishnan-jayu/bin-go/bingo"
+	"github.com/jayakrishnan-jayu/bin-go/bingo/discovery"
+)
+
+// choice is a room the player can pick from the servers heard on the LAN.
+type choice struct {
+	server discovery.Server
+	room   bingo.RoomInfo
+}
+
+func describeRoom(room bingo.RoomInfo) string {
+	state := "lobby open"
+	if !room.IsLobbyMode {
+		state = "playing"
+	}
+	return fmt.Sprintf("%s: %d players, %d watching, %s", room.ID, room.Players, room.Spectators, state)
+}
+
+// discoverServer lists the servers announcing themselves on the LAN and
+// points the -i, -p, -r and -tls flags at the room the player picks.
+func discoverServer() {
+	fmt.Printf("Looking for servers for %s...\n", *discoverWait)
+	servers, err := discovery.Discover(*discoverWait)
+	if err != nil {
+		log.Fatal("discover: ", err)
+	}
+	var choices []choice
+	for _, s := range servers {
+		fmt.Printf("%s (%s)", s.Name, s.Addr())
+		if s.TLS {
+			fmt.Print(" TLS")
+		}
+		if s.Protocol < bingo.MinProtocolVersion || s.Protocol > bingo.ProtocolVersion {
+			fmt.Println(", speaks another protocol version")
+			continue
+		}
+		fmt.Println()
+		rooms := s.Rooms
+		if len(rooms) == 0 {
+			// Rooms are only created once someone joins them.
+			rooms = []bingo.RoomInfo{{ID: bingo.DefaultRoom, IsLobbyMode: true}}
+		}
+		for _, room := range rooms {
+			choices = append(choices, choice{server: s, room: room})
+			fmt.Printf("  %d) %s\n", len(choices), describeRoom(room))
+		}
+	}
+	if len(choices) == 0 {
+		log.Fatal("discover: no servers found, is one running with -announce on this network?")
+	}
+
+	fmt.Print("Pick a room: ")
+	for {
+		n, err := strconv.Atoi(<-inputs)
+		if err != nil || n < 1 || n > len(choices) {
+			fmt.Printf("Enter a number from 1 to %d: ", len(choices))
+			continue
+		}
+		c := choices[n-1]
+		*serverIp = c.server.IP.String()
+		*port = c.server.Port
+		*room = c.room.ID
+		*useTLS = *useTLS || c.server.TLS
+		return
+	}
+}
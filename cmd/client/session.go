@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sessionPath returns the file the resume token for this server, room and
+// username is kept in.
+func sessionPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	name := url.PathEscape(fmt.Sprintf("%s_%d_%s_%s", *serverIp, *port, *room, *username))
+	return filepath.Join(dir, "bin-go", name+".token"), nil
+}
+
+func saveSession(token string) error {
+	path, err := sessionPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(token), 0600)
+}
+
+func loadSession() (string, error) {
+	path, err := sessionPath()
+	if err != nil {
+		return "", err
+	}
+	token, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(token)), nil
+}
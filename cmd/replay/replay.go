@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jayakrishnan-jayu/bin-go/bingo"
+	"github.com/jayakrishnan-jayu/bin-go/bingo/engine"
+)
+
+var speed = flag.Float64("speed", 1, "Playback speed, 2 plays twice as fast as the game was played")
+var maxDelay = flag.Duration("max-delay", 2*time.Second, "Longest pause between two moves, before -speed is applied")
+var paused = flag.Bool("step", false, "Start paused and step through the moves by hand")
+
+// frame is the game after one entry of the journal.
+type frame struct {
+	state engine.State
+	time  time.Time
+	// What happened, for the log under the boards.
+	line string
+}
+
+type replay struct {
+	config bingo.GameConfig
+	room   string
+	frames []frame
+	result *bingo.GameResult
+}
+
+func load(path string) (*replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	entries, err := bingo.ReadJournal(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 || entries[0].Type != bingo.JournalStart || entries[0].Config == nil {
+		return nil, fmt.Errorf("%s does not start with a %s entry", path, bingo.JournalStart)
+	}
+	start := entries[0]
+	r := &replay{config: *start.Config, room: start.Room}
+	players := make([]engine.Player, len(start.Players))
+	for i, p := range start.Players {
+		players[i] = engine.Player{ID: p.Id, Name: p.Name, Board: p.Board}
+	}
+	state := engine.New(r.config.Rules(), players)
+	r.frames = append(r.frames, frame{state: state, time: start.Time, line: "Game started"})
+
+	for i, entry := range entries[1:] {
+		switch entry.Type {
+		case bingo.JournalMove:
+			if entry.Move == nil {
+				return nil, fmt.Errorf("entry %d: move is missing", i+2)
+			}
+			move := engine.Move{Player: entry.Move.PlayerId, Number: entry.Move.Number, Auto: entry.Move.Auto}
+			next, events, err := state.Apply(move)
+			if err != nil {
+				return nil, fmt.Errorf("entry %d: %w", i+2, err)
+			}
+			state = next
+			r.frames = append(r.frames, frame{state: state, time: entry.Time, line: describe(state, move, events)})
+		case bingo.JournalLeave:
+			state = state.Leave(entry.PlayerId)
+			r.frames = append(r.frames, frame{state: state, time: entry.Time, line: name(state, entry.PlayerId) + " left"})
+		case bingo.JournalResult:
+			r.result = entry.Result
+		}
+	}
+	return r, nil
+}
+
+func name(state engine.State, id uint8) string {
+	p, _ := state.Player(id)
+	return p.Name
+}
+
+// describe writes a move and what it caused on one line.
+func describe(state engine.State, move engine.Move, events []engine.Event) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\t%d", name(state, move.Player), move.Number)
+	if move.Auto {
+		b.WriteString("\t(timed out)")
+	}
+	for _, event := range events {
+		if e, ok := event.(engine.Finished); ok {
+			fmt.Fprintf(&b, "\t%s finished #%d", name(state, e.Player), e.Place)
+		}
+	}
+	return b.String()
+}
+
+// view shows a frame like the server shows the game to spectators.
+func (r *replay) view(i int) bingo.SpectatorView {
+	state := r.frames[i].state
+	view := bingo.SpectatorView{}
+	for _, p := range state.Players {
+		view.Players = append(view.Players, bingo.PlayerView{
+			Id:         p.ID,
+			Name:       p.Name,
+			Board:      p.Board,
+			Score:      p.Lines,
+			ScoreIndex: p.Place,
+			Connected:  !p.Left,
+		})
+	}
+	for n, crossed := range state.Crossed {
+		if crossed {
+			view.Crossed = append(view.Crossed, uint8(n))
+		}
+	}
+	if i > 0 && len(state.Moves) > 0 {
+		view.CurrentPlayer = state.Moves[len(state.Moves)-1].Player
+	}
+	return view
+}
+
+func (r *replay) render(i int, playing bool) {
+	bingo.RenderSpectatorView(r.view(i), r.config.WinLines)
+	from := i - 4
+	if from < 0 {
+		from = 0
+	}
+	for _, f := range r.frames[from : i+1] {
+		fmt.Println(f.line)
+	}
+	fmt.Println()
+	if i == len(r.frames)-1 && r.result != nil {
+		bingo.RenderStandings(*r.result)
+		fmt.Println()
+	}
+	state := "paused"
+	if playing {
+		state = "playing"
+	}
+	fmt.Printf("Room %s, seed %d, step %d/%d, %gx, %s\n", r.room, r.config.Seed, i, len(r.frames)-1, *speed, state)
+	fmt.Println("Enter: next, b: back, p: play/pause, +/-: speed, q: quit")
+}
+
+// delay returns how long to wait before showing frame i.
+func (r *replay) delay(i int) time.Duration {
+	d := r.frames[i].time.Sub(r.frames[i-1].time)
+	if d > *maxDelay {
+		d = *maxDelay
+	}
+	if d < 0 {
+		d = 0
+	}
+	return time.Duration(float64(d) / *speed)
+}
+
+func readInput(input chan<- string) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		input <- strings.TrimSpace(scanner.Text())
+	}
+	close(input)
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] journal.jsonl\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *speed <= 0 {
+		log.Fatal("speed must be positive")
+	}
+	r, err := load(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	input := make(chan string)
+	go readInput(input)
+	last := len(r.frames) - 1
+	i, playing := 0, !*paused
+	for {
+		r.render(i, playing)
+		if input == nil && i == last {
+			return
+		}
+		var timer *time.Timer
+		var next <-chan time.Time
+		if playing && i < last {
+			timer = time.NewTimer(r.delay(i + 1))
+			next = timer.C
+		}
+		select {
+		case <-next:
+			i++
+		case cmd, ok := <-input:
+			if !ok {
+				// Without a keyboard the replay plays to the end.
+				input = nil
+				playing = true
+				break
+			}
+			switch cmd {
+			case "", "n":
+				if i < last {
+					i++
+				}
+			case "b":
+				if i > 0 {
+					i--
+				}
+			case "p":
+				playing = !playing
+			case "+":
+				*speed *= 2
+			case "-":
+				*speed /= 2
+			case "q":
+				return
+			}
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
@@ -1,30 +1,299 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/jayakrishnan-jayu/bin-go/bingo"
+	"github.com/jayakrishnan-jayu/bin-go/bingo/bot"
+	"github.com/jayakrishnan-jayu/bin-go/bingo/discovery"
 	"github.com/jayakrishnan-jayu/bin-go/utils"
+	"io/fs"
 	"log"
+	"math"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 )
 
-var port = flag.Int("p", 8080, "Port address of the server")
+var port = flag.Int("p", 8080, "Port to listen on every interface, when -listen is not given")
+var listenAddrs = flag.String("listen", "", "Comma separated addresses to listen on: host:port ([::1]:8080 for IPv6, :8080 for every interface), unix:/path/to.sock or systemd")
+var boardSize = flag.Uint("size", uint(bingo.DefaultGameOptions.BoardSize), "Number of rows and columns on each board")
+var winLines = flag.Uint("lines", uint(bingo.DefaultGameOptions.WinLines), "Completed lines needed to win")
+var diagonals = flag.Bool("diagonals", bingo.DefaultGameOptions.Diagonals, "Count diagonals as lines")
+var maxPlayers = flag.Int("max-players", bingo.DefaultGameOptions.MaxPlayers, "Maximum players in a room, 0 for no limit")
+var endCondition = flag.String("end", string(bingo.DefaultGameOptions.EndCondition), "When the game ends: first (first winner), top (-finishers winners) or all")
+var finishers = flag.Int("finishers", bingo.DefaultGameOptions.Finishers, "Winners needed to end the game with -end top")
+var turnTimeout = flag.Duration("turn-timeout", bingo.DefaultGameOptions.TurnTimeout, "Time each player has to move, 0 for no limit")
+var timeoutPolicy = flag.String("timeout-policy", string(bingo.DefaultGameOptions.TimeoutPolicy), "What happens when a turn times out: skip, random or eject")
+var reconnectGrace = flag.Duration("reconnect-grace", bingo.DefaultGameOptions.ReconnectGrace, "How long a disconnected player's seat is held, 0 to remove them straight away")
+var maxTimeouts = flag.Int("max-timeouts", bingo.DefaultGameOptions.MaxTimeouts, "Consecutive timeouts before a player is ejected")
+var seed = flag.Int64("seed", bingo.DefaultGameOptions.Seed, "Seed for the boards and random moves, 0 to pick one at random")
+var journalDir = flag.String("journal", "", "Directory to write a journal of every round to, empty to keep none")
+var statsFile = flag.String("stats", "", "File to keep player stats in across restarts, empty to keep none")
+var password = flag.String("password", "", "Password players and spectators need to enter a room, empty for none")
+var usersFile = flag.String("users", "", "File of \"username token\" lines, the only users allowed to play")
+var tlsCert = flag.String("tls-cert", "", "Certificate file to serve TLS (wss://) with, needs -tls-key")
+var tlsKey = flag.String("tls-key", "", "Private key file of -tls-cert")
+var tlsGenerate = flag.Bool("tls-generate", false, "Create a self-signed certificate at -tls-cert and -tls-key if they do not exist yet")
+var origins = flag.String("origins", "", "Comma separated hosts web pages may open the game from, * for any")
+var allowNets = flag.String("allow", "", "Comma separated networks (CIDR) clients may connect from, empty for any")
+var denyNets = flag.String("deny", "", "Comma separated networks (CIDR) clients may not connect from")
+var maxPerIP = flag.Int("max-per-ip", 0, "Connections one IP address may have open at once, 0 for no limit")
+var saveDir = flag.String("save", "", "Directory to save rounds in progress to on shutdown and resume them from on start, empty to save none")
+var shutdownTimeout = flag.Duration("shutdown-timeout", 10*time.Second, "How long to wait for clients to be told and disconnected when shutting down")
+var announce = flag.Bool("announce", true, "Announce the server on the LAN so clients can find it with -discover")
+var maxErrors = flag.Int("max-errors", bingo.DefaultGameOptions.MaxErrors, "Protocol errors before a client is disconnected, 0 to never disconnect")
 
 func main() {
+	flag.Parse()
+
+	options := bingo.GameOptions{
+		BoardSize:  uint8(*boardSize),
+		WinLines:   uint8(*winLines),
+		Diagonals:  *diagonals,
+		MaxPlayers: *maxPlayers,
+
+		EndCondition: bingo.EndCondition(*endCondition),
+		Finishers:    *finishers,
+
+		TurnTimeout:   *turnTimeout,
+		TimeoutPolicy: bingo.TimeoutPolicy(*timeoutPolicy),
+		MaxTimeouts:   *maxTimeouts,
+
+		ReconnectGrace: *reconnectGrace,
+
+		MaxErrors: *maxErrors,
+		Agents:    bot.Agents(),
+
+		Seed:       *seed,
+		JournalDir: *journalDir,
+		SaveDir:    *saveDir,
+
+		Password: *password,
+	}
+	admission, err := newAdmission()
+	if err != nil {
+		log.Fatal("invalid admission policy: ", err)
+	}
+	options.Admission = admission
+	if *boardSize > math.MaxUint8 || *winLines > math.MaxUint8 {
+		log.Fatal("invalid game options: board size or lines to win too large")
+	}
+	if err := options.Validate(); err != nil {
+		log.Fatal("invalid game options: ", err)
+	}
+	if *usersFile != "" {
+		users, err := bingo.LoadUserTokens(*usersFile)
+		if err != nil {
+			log.Fatal("users: ", err)
+		}
+		options.Users = users
+	}
+	if *statsFile != "" {
+		stats, err := bingo.OpenFileStats(*statsFile)
+		if err != nil {
+			log.Fatal("stats: ", err)
+		}
+		options.Stats = stats
+	}
+	if options.JournalDir != "" {
+		if err := os.MkdirAll(options.JournalDir, 0755); err != nil {
+			log.Fatal("journal: ", err)
+		}
+	}
+	if options.SaveDir != "" {
+		if err := os.MkdirAll(options.SaveDir, 0700); err != nil {
+			log.Fatal("save: ", err)
+		}
+	}
+
+	if (*tlsCert == "") != (*tlsKey == "") {
+		log.Fatal("tls: -tls-cert and -tls-key go together")
+	}
+	if *tlsGenerate && *tlsCert == "" {
+		log.Fatal("tls: -tls-generate needs -tls-cert and -tls-key to write to")
+	}
+
+	listen := *listenAddrs
+	if listen == "" {
+		listen = fmt.Sprintf(":%d", *port)
+	}
+	listeners, err := utils.Listen(listen)
+	if err != nil {
+		log.Fatal("listen: ", err)
+	}
+	var reachable []string
+	for _, l := range listeners {
+		reachable = append(reachable, utils.ReachableAddrs(l)...)
+	}
 
 	ip, err := utils.GetLocalIP()
 	if err != nil {
 		log.Println(err)
 		ip = "localhost"
 	}
-	addr := fmt.Sprintf("%s:%d", ip, *port)
-	bingo := bingo.New(net.ParseIP(ip))
-	go bingo.Run()
+	rooms := bingo.NewRoomManager(net.ParseIP(ip), options)
+	restored, err := rooms.Restore()
+	if err != nil {
+		log.Println("save: ", err)
+	}
+	for _, id := range restored {
+		log.Printf("Room %s restored, its players can rejoin with -resume\n", id)
+	}
+	go rooms.Run()
+
+	http.Handle("/ws", rooms)
+	http.Handle("/ws/", rooms)
+	http.HandleFunc("/rooms", rooms.ServeRoomList)
+	if *announce {
+		if port := announcedPort(listeners); port != 0 {
+			go announceServer(rooms, port)
+		} else {
+			log.Println("Not announcing the server, it only listens on this machine")
+		}
+	}
+
+	srv := &http.Server{}
+	serve := func(l net.Listener) error { return srv.Serve(l) }
+	if *tlsCert == "" {
+		log.Println("Starting Server")
+	} else {
+		if *tlsGenerate {
+			if err := generateCertificate(reachable); err != nil {
+				log.Fatal("tls: ", err)
+			}
+		}
+		cert, err := tls.LoadX509KeyPair(*tlsCert, *tlsKey)
+		if err != nil {
+			log.Fatal("tls: ", err)
+		}
+		log.Println("Starting Server with TLS")
+		log.Printf("Certificate fingerprint (for -fingerprint): %s\n", utils.Fingerprint(cert.Certificate[0]))
+		serve = func(l net.Listener) error { return srv.ServeTLS(l, *tlsCert, *tlsKey) }
+	}
+	for _, addr := range reachable {
+		log.Printf("Listening on %s\n", addr)
+	}
+	errs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func(l net.Listener) {
+			errs <- serve(l)
+		}(l)
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-errs:
+		log.Fatal(err)
+	case sig := <-signals:
+		// A second signal kills the server straight away.
+		signal.Stop(signals)
+		log.Printf("Received %s, shutting down\n", sig)
+	}
+	shutdown(srv, rooms)
+}
+
+// shutdown stops accepting connections, tells every client the server is
+// going down and waits up to -shutdown-timeout for them to be disconnected.
+func shutdown(srv *http.Server, rooms *bingo.RoomManager) {
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	closed := make(chan error, 1)
+	go func() {
+		closed <- srv.Shutdown(ctx)
+	}()
+	if err := rooms.Shutdown(ctx, "The server is shutting down"); err != nil {
+		log.Println("shutdown: clients did not disconnect in time: ", err)
+	}
+	if err := <-closed; err != nil {
+		log.Println("shutdown: ", err)
+	}
+	log.Println("Server stopped")
+}
+
+// announcedPort returns the port of the first TCP listener other machines
+// can reach, the one the server is announced with, or 0 if it has none.
+// Loopback addresses and unix sockets are not worth announcing.
+func announcedPort(listeners []net.Listener) int {
+	for _, l := range listeners {
+		if addr, ok := l.Addr().(*net.TCPAddr); ok && !addr.IP.IsLoopback() {
+			return addr.Port
+		}
+	}
+	return 0
+}
+
+// announceServer broadcasts the server and its rooms on the LAN.
+func announceServer(rooms *bingo.RoomManager, port int) {
+	name, err := os.Hostname()
+	if err != nil {
+		name = "bingo"
+	}
+	beacon := discovery.Beacon{
+		ID:       utils.NewToken(),
+		Name:     name,
+		Port:     port,
+		TLS:      *tlsCert != "",
+		Protocol: bingo.ProtocolVersion,
+	}
+	err = discovery.Announce(func() discovery.Beacon {
+		b := beacon
+		b.Rooms = rooms.List()
+		return b
+	}, nil)
+	if err != nil {
+		log.Println("announce: ", err)
+	}
+}
 
-	http.Handle("/ws", bingo)
-	log.Printf("Starting Server on %s\n", addr)
-	log.Fatal(http.ListenAndServe(addr, nil))
+// newAdmission builds the admission policy from the flags, nil if none of
+// them are set.
+func newAdmission() (*bingo.Admission, error) {
+	if *origins == "" && *allowNets == "" && *denyNets == "" && *maxPerIP == 0 {
+		return nil, nil
+	}
+	allow, err := bingo.ParseNetworks(*allowNets)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := bingo.ParseNetworks(*denyNets)
+	if err != nil {
+		return nil, err
+	}
+	a := &bingo.Admission{Allow: allow, Deny: deny, MaxPerIP: *maxPerIP}
+	for _, origin := range strings.Split(*origins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			a.Origins = append(a.Origins, origin)
+		}
+	}
+	return a, nil
+}
 
+// generateCertificate creates a self-signed certificate for the addresses
+// the server is reachable on, unless -tls-cert already exists.
+func generateCertificate(reachable []string) error {
+	if _, err := os.Stat(*tlsCert); !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	for _, addr := range reachable {
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			hosts = append(hosts, host)
+		}
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		hosts = append(hosts, hostname)
+	}
+	if err := utils.GenerateCertificate(*tlsCert, *tlsKey, hosts); err != nil {
+		return err
+	}
+	log.Printf("Created a self-signed certificate in %s, players can trust it with -ca\n", *tlsCert)
+	return nil
 }
@@ -2,4 +2,4 @@ module github.com/jayakrishnan-jayu/bin-go
 
 go 1.18
 
-require github.com/gorilla/websocket v1.5.0 // indirect
+require github.com/gorilla/websocket v1.5.0
@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	// UnixPrefix marks a listen address as a Unix domain socket path.
+	UnixPrefix = "unix:"
+	// SystemdListen takes the sockets systemd passed to the process.
+	SystemdListen = "systemd"
+	// The first file descriptor systemd passes sockets on.
+	systemdFirstFD = 3
+)
+
+// Listen opens a listener for every address in a comma separated list.
+// An address is a TCP host:port, where an empty host or 0.0.0.0 listens on
+// every interface and IPv6 hosts go in brackets, a Unix socket path
+// prefixed with "unix:", or "systemd" for the sockets systemd activated the
+// process with.
+func Listen(list string) ([]net.Listener, error) {
+	var listeners []net.Listener
+	fail := func(err error) ([]net.Listener, error) {
+		for _, l := range listeners {
+			l.Close()
+		}
+		return nil, err
+	}
+	for _, addr := range strings.Split(list, ",") {
+		addr = strings.TrimSpace(addr)
+		switch {
+		case addr == "":
+			continue
+		case addr == SystemdListen:
+			activated, err := systemdListeners()
+			if err != nil {
+				return fail(err)
+			}
+			listeners = append(listeners, activated...)
+		case strings.HasPrefix(addr, UnixPrefix):
+			path := strings.TrimPrefix(addr, UnixPrefix)
+			if err := removeStaleSocket(path); err != nil {
+				return fail(err)
+			}
+			l, err := net.Listen("unix", path)
+			if err != nil {
+				return fail(err)
+			}
+			listeners = append(listeners, l)
+		default:
+			network := "tcp"
+			if host, _, err := net.SplitHostPort(addr); err == nil && host == "0.0.0.0" {
+				network = "tcp4"
+			}
+			l, err := net.Listen(network, addr)
+			if err != nil {
+				return fail(err)
+			}
+			listeners = append(listeners, l)
+		}
+	}
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("no address to listen on")
+	}
+	return listeners, nil
+}
+
+// removeStaleSocket removes a socket left behind at path by a server that
+// is no longer running, which would stop the listen. A socket something
+// still answers on is left alone.
+func removeStaleSocket(path string) error {
+	info, err := os.Stat(path)
+	if err != nil || info.Mode()&os.ModeSocket == 0 {
+		return nil
+	}
+	conn, err := net.Dial("unix", path)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("listen unix %s: address already in use", path)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return nil
+	}
+	return os.Remove(path)
+}
+
+// systemdListeners returns the sockets passed by systemd socket activation,
+// as described by the LISTEN_PID and LISTEN_FDS variables.
+func systemdListeners() ([]net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, fmt.Errorf("systemd: no sockets were passed to this process")
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("systemd: no sockets were passed to this process")
+	}
+	// Children must not think the sockets were meant for them.
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	listeners := make([]net.Listener, 0, n)
+	for fd := systemdFirstFD; fd < systemdFirstFD+n; fd++ {
+		f := os.NewFile(uintptr(fd), fmt.Sprintf("systemd-%d", fd))
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("systemd: socket %d: %w", fd, err)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+// ReachableAddrs returns the addresses clients can reach a listener on. A
+// listener on every interface is reachable on each of their addresses.
+func ReachableAddrs(l net.Listener) []string {
+	tcp, ok := l.Addr().(*net.TCPAddr)
+	if !ok {
+		return []string{l.Addr().Network() + ":" + l.Addr().String()}
+	}
+	if !tcp.IP.IsUnspecified() {
+		return []string{tcp.String()}
+	}
+	ipv4Only := tcp.IP.To4() != nil
+	ifaceAddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return []string{tcp.String()}
+	}
+	var addrs []string
+	for _, a := range ifaceAddrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok || ipv4Only && ipnet.IP.To4() == nil {
+			continue
+		}
+		// Link-local IPv6 addresses need a zone, which players cannot use
+		// without knowing the interface.
+		if ipnet.IP.To4() == nil && ipnet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		addrs = append(addrs, net.JoinHostPort(ipnet.IP.String(), strconv.Itoa(tcp.Port)))
+	}
+	return addrs
+}
@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// How long generated certificates are valid for.
+const CertificateLifetime = 365 * 24 * time.Hour
+
+var ErrFingerprintMismatch = errors.New("server certificate does not match the pinned fingerprint")
+
+// GenerateCertificate writes a self-signed certificate for hosts, which may
+// be IP addresses or names, and its private key as PEM files. The
+// certificate can be given to clients as their CA.
+func GenerateCertificate(certFile, keyFile string, hosts []string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"bin-go"}, CommonName: hosts[0]},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(CertificateLifetime),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := writePEM(keyFile, "PRIVATE KEY", keyDER, 0600); err != nil {
+		return err
+	}
+	return writePEM(certFile, "CERTIFICATE", der, 0644)
+}
+
+func writePEM(path, blockType string, der []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if err := pem.Encode(f, &pem.Block{Type: blockType, Bytes: der}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a DER encoded certificate,
+// as colon separated hex bytes.
+func Fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// normalizeFingerprint lets fingerprints be written with or without colons,
+// in either case.
+func normalizeFingerprint(fingerprint string) (string, error) {
+	hexDigits := strings.ToUpper(strings.ReplaceAll(fingerprint, ":", ""))
+	if b, err := hex.DecodeString(hexDigits); err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("fingerprint must be a SHA-256 hash in hex")
+	}
+	return hexDigits, nil
+}
+
+// ClientTLSConfig returns the TLS config a client connects with. caFile adds
+// a certificate to trust on top of the system roots, insecure skips
+// verification altogether, and a pinned fingerprint only accepts a server
+// certificate with that fingerprint, self-signed or not.
+func ClientTLSConfig(caFile string, insecure bool, pin string) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: insecure}
+	if caFile != "" {
+		pemData, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pemData) {
+			return nil, fmt.Errorf("%s holds no PEM certificates", caFile)
+		}
+		config.RootCAs = pool
+	}
+	if pin != "" {
+		want, err := normalizeFingerprint(pin)
+		if err != nil {
+			return nil, err
+		}
+		// The pin replaces the usual chain checks.
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return ErrFingerprintMismatch
+			}
+			got, _ := normalizeFingerprint(Fingerprint(rawCerts[0]))
+			if got != want {
+				return ErrFingerprintMismatch
+			}
+			return nil
+		}
+	}
+	return config, nil
+}
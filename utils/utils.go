@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"log"
 	"net"
@@ -18,7 +20,23 @@ const (
 	PingPeriod = (PongWait * 8) / 10
 
 	// Maximum message size allowed from peer.
-	MaxMessageSize = 512
+	MaxMessageSize = 4096
+
+	// Maximum message size a client accepts from the server, which batches
+	// queued messages and sends the whole game state to resuming players.
+	MaxServerMessageSize = 1 << 20
+
+	// How often finished rooms are removed from the server.
+	RoomGCPeriod = 30 * time.Second
+
+	// How long a room nobody has joined is kept while it stands empty.
+	EmptyRoomGrace = time.Minute
+
+	// Maximum length of a room ID.
+	MaxRoomIDLength = 32
+
+	// Maximum length of a player name, in bytes.
+	MaxNameLength = 24
 )
 
 var (
@@ -42,11 +60,11 @@ func GetLocalIP() (string, error) {
 	return "", errors.New("IPv4 address not found")
 }
 
-func GetCommandFromMap(messageMap map[string]interface{}) (int, bool) {
-	cmd, ok := messageMap["command"].(float64)
-	if !ok {
-		log.Fatal("Command not found or Invalid Command in response")
-		return -1, false
+// NewToken returns a random hex string for identifying a session.
+func NewToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatal("NewToken: ", err)
 	}
-	return int(cmd), true
+	return hex.EncodeToString(b)
 }